@@ -0,0 +1,72 @@
+package logrus_logstash
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// shipCounted is the tail of ship for hooks with an AttemptCountField: the
+// entry is formatted again before every attempt, so that the attempt which
+// delivers it carries its own number.
+func (h *Hook) shipCounted(writer io.Writer, isConn bool, entry *logrus.Entry) error {
+	h.number(entry)
+	start := time.Now()
+	attempt, formatted, err := h.writeCounted(writer, entry, 1)
+	if err != nil && isConn && h.WarmStandby {
+		if standby := h.failover(); standby != nil {
+			_, formatted, err = h.writeCounted(standby, entry, attempt+1)
+		}
+	}
+	if isConn {
+		h.connWritten(err)
+	}
+	if err != nil {
+		h.countFailed()
+		return err
+	}
+	h.countSent(start)
+	h.remember(formatted)
+	if isConn && h.RotateBytes > 0 {
+		return h.rotateFile()
+	}
+	return nil
+}
+
+// writeCounted writes the entry to w, numbering attempts from first, and
+// returns the number of the last one, along with the entry it wrote as
+// formatted for the ring buffer. Only a write failing with a temporary
+// error before any of the entry went through is attempted again, up to
+// maxWriteRetries times; once part of it is written, the rest follows as is.
+func (h *Hook) writeCounted(w io.Writer, entry *logrus.Entry, first int) (int, []byte, error) {
+	for attempt := first; ; attempt++ {
+		entry.Data[h.AttemptCountField] = attempt
+		data, formatted, err := h.encodeNumbered(entry)
+		if err != nil {
+			return attempt, nil, err
+		}
+		n := len(data)
+		if n > writeChunkSize && streamConn(w) {
+			n = writeChunkSize
+		}
+		written, err := w.Write(data[:n])
+		if err == nil {
+			written = n
+		} else if netErr, ok := err.(net.Error); !ok || !netErr.Temporary() {
+			return attempt, nil, err
+		} else if written == 0 {
+			if attempt-first >= maxWriteRetries {
+				return attempt, nil, err
+			}
+			continue
+		}
+		if written < len(data) {
+			if err := write(w, data[written:]); err != nil {
+				return attempt, nil, err
+			}
+		}
+		return attempt, formatted, nil
+	}
+}
@@ -0,0 +1,47 @@
+package logrus_logstash
+
+import (
+	"errors"
+	"time"
+)
+
+// goBackground runs f in a goroutine of the hook, such as the one shipping
+// heartbeats, until Close closes done.
+func (h *Hook) goBackground(f func(done <-chan struct{})) {
+	h.mu.Lock()
+	if h.done == nil {
+		h.done = make(chan struct{})
+	}
+	done := h.done
+	h.background.Add(1)
+	h.mu.Unlock()
+
+	go func() {
+		defer h.background.Done()
+		f(done)
+	}()
+}
+
+// stopBackground closes done and waits up to timeout for the hook's
+// goroutines to return. Goroutines started afterwards run until the next
+// Close.
+func (h *Hook) stopBackground(timeout time.Duration) error {
+	h.mu.Lock()
+	if h.done != nil {
+		close(h.done)
+		h.done = nil
+	}
+	h.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		h.background.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("Closed before background goroutines returned")
+	}
+}
@@ -0,0 +1,229 @@
+package logrus_logstash
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBatchInterval = time.Second
+
+// sharedBatch keys the batch of entries of all levels, unless BatchPerLevel
+// is set. Panic entries are never batched, so it can't be mistaken for the
+// batch of a level.
+const sharedBatch = logrus.PanicLevel
+
+// pendingBatch holds the formatted entries of a batch until it is written.
+type pendingBatch struct {
+	level     logrus.Level
+	entries   [][]byte
+	formatted [][]byte
+	timer     *time.Timer
+}
+
+// batched reports whether entries of the given level ship in batches. Panic
+// and Fatal entries ship right away, after the batch.
+func (h *Hook) batched(level logrus.Level) bool {
+	if h.BatchSize <= 1 || level <= logrus.FatalLevel || h.Durable || h.syslog != nil || h.pooled(level) {
+		return false
+	}
+	return h.levelConn(level) == nil
+}
+
+// batchKey returns the key of the batch entries of the given level go to.
+func (h *Hook) batchKey(level logrus.Level) logrus.Level {
+	if h.BatchPerLevel {
+		return level
+	}
+	return sharedBatch
+}
+
+// enqueue formats the entry and adds it to its batch, writing the batch once
+// it holds BatchSize entries.
+func (h *Hook) enqueue(entry *logrus.Entry, started time.Time) error {
+	if h.BatchSeparator != nil && len(h.BatchSeparator) == 0 {
+		h.countFailed()
+		return errors.New("BatchSeparator must not be empty")
+	}
+	h.recordWaitDuration(entry, started)
+	data, formatted, err := h.encode(entry)
+	if err != nil {
+		h.countFailed()
+		return err
+	}
+
+	key := h.batchKey(entry.Level)
+	h.mu.Lock()
+	if h.batches == nil {
+		h.batches = make(map[logrus.Level]*pendingBatch)
+	}
+	batch := h.batches[key]
+	if batch == nil {
+		interval := h.BatchInterval
+		if interval <= 0 {
+			interval = defaultBatchInterval
+		}
+		batch = &pendingBatch{level: entry.Level}
+		batch.timer = time.AfterFunc(interval, func() {
+			h.reportError(h.flushBatchOf(key))
+		})
+		h.batches[key] = batch
+	}
+	batch.entries = append(batch.entries, data)
+	batch.formatted = append(batch.formatted, formatted)
+	full := len(batch.entries) >= h.BatchSize
+	h.mu.Unlock()
+
+	if full {
+		return h.flushBatchOf(key)
+	}
+	return nil
+}
+
+// batchLen returns the number of entries in the batches.
+func (h *Hook) batchLen() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, batch := range h.batches {
+		n += len(batch.entries)
+	}
+	return n
+}
+
+// flushBatch writes the batches, those of the most severe levels first.
+func (h *Hook) flushBatch() error {
+	h.mu.Lock()
+	keys := make([]logrus.Level, 0, len(h.batches))
+	for key := range h.batches {
+		keys = append(keys, key)
+	}
+	h.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	var firstErr error
+	for _, key := range keys {
+		if err := h.flushBatchOf(key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// flushBatchOf writes the entries of the batch with the given key, if any, at
+// once, each followed by the BatchSeparator. Length-prefixed entries are
+// written back to back, and those POSTed to an HTTPEndpoint as a JSON array.
+func (h *Hook) flushBatchOf(key logrus.Level) error {
+	h.mu.Lock()
+	batch := h.batches[key]
+	delete(h.batches, key)
+	h.mu.Unlock()
+	if batch == nil {
+		return nil
+	}
+	batch.timer.Stop()
+
+	h.jitterReconnect(batch.level)
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	writer, isConn, err := h.writerFor(batch.level)
+	if err != nil {
+		for range batch.entries {
+			h.countFailed()
+		}
+		return err
+	}
+	if writer == nil {
+		return nil
+	}
+	start := time.Now()
+	payload, err := h.batchPayload(batch.entries, postsHTTP(writer))
+	if err == nil {
+		err = write(writer, payload)
+		if isConn {
+			h.connWritten(err)
+		}
+	}
+	for i := range batch.entries {
+		if err != nil {
+			h.countFailed()
+		} else {
+			h.countSent(start)
+			h.remember(batch.formatted[i])
+		}
+	}
+	if err == nil && isConn && h.RotateBytes > 0 {
+		return h.rotateFile()
+	}
+	return err
+}
+
+// batchPayload joins the entries of a batch. The JSON codec of the Logstash
+// `http` input decodes a single document or an array of them, so Logstash
+// JSON entries POSTed over HTTP are joined into an array, and Loki pushes
+// are merged into a single push. Other entries are joined like those written
+// to connections.
+func (h *Hook) batchPayload(entries [][]byte, http bool) ([]byte, error) {
+	if http && !h.LengthPrefixFraming && !h.ContentLengthTrailer {
+		h.mu.Lock()
+		formatter := h.Formatter
+		h.mu.Unlock()
+		switch formatter.(type) {
+		case nil, *LogstashFormatter:
+			return jsonArray(entries), nil
+		case *LokiFormatter:
+			return mergeLokiPushes(entries)
+		}
+	}
+
+	var payload []byte
+	separator := h.BatchSeparator
+	if separator == nil {
+		separator = []byte("\n")
+	}
+	for _, data := range entries {
+		if h.LengthPrefixFraming {
+			payload = append(payload, data...)
+			continue
+		}
+		payload = append(payload, trimNewline(data)...)
+		payload = append(payload, separator...)
+	}
+	return payload, nil
+}
+
+// jsonArray joins JSON documents into an array.
+func jsonArray(docs [][]byte) []byte {
+	payload := []byte{'['}
+	for i, doc := range docs {
+		if i > 0 {
+			payload = append(payload, ',')
+		}
+		payload = append(payload, trimNewline(doc)...)
+	}
+	return append(payload, ']', '\n')
+}
+
+// postsHTTP reports whether w POSTs its writes to an HTTPEndpoint.
+func postsHTTP(w io.Writer) bool {
+	switch w := w.(type) {
+	case httpWriter:
+		return true
+	case sharedWriter:
+		return w.h.levelConn(w.level) == nil && w.h.syslog == nil && w.h.HTTPEndpoint != ""
+	}
+	return false
+}
+
+// trimNewline strips the trailing newline of a formatted entry.
+func trimNewline(data []byte) []byte {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		return data[:n-1]
+	}
+	return data
+}
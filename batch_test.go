@@ -0,0 +1,183 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireBatchSeparator(t *testing.T) {
+	writes := make(chanWriter, 10)
+	hook := &Hook{
+		conn:             writes,
+		appName:          "batch_test",
+		alwaysSentFields: logrus.Fields{},
+		BatchSize:        3,
+		BatchInterval:    time.Hour,
+		BatchSeparator:   []byte{0},
+	}
+	fire := func(message string) {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		fire(fmt.Sprint(i))
+	}
+	if len(writes) != 1 {
+		t.Fatalf("expected a single write but got %d", len(writes))
+	}
+	payload := <-writes
+	if payload[len(payload)-1] != 0 {
+		t.Errorf("expected the payload to end with the separator but got '%q'", payload)
+	}
+	entries := bytes.Split(payload[:len(payload)-1], []byte{0})
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries but got %d in '%q'", len(entries), payload)
+	}
+	for i, b := range entries {
+		var res map[string]string
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != fmt.Sprint(i+1) {
+			t.Errorf("expected message to be '%d' but got '%s'", i+1, res["message"])
+		}
+	}
+
+	// Partial batches ship on Flush.
+	fire("4")
+	if len(writes) != 0 {
+		t.Fatal("expected a partial batch to be held back")
+	}
+	if err := hook.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if payload := <-writes; bytes.Count(payload, []byte{0}) != 1 {
+		t.Errorf("expected a single entry but got '%q'", payload)
+	}
+
+	hook.BatchSeparator = []byte{}
+	if err := hook.Fire(&logrus.Entry{Message: "5", Data: logrus.Fields{}, Level: logrus.InfoLevel}); err == nil {
+		t.Error("expected Fire to fail with an empty separator")
+	}
+}
+
+func TestFireBatchInterval(t *testing.T) {
+	writes := make(chanWriter, 10)
+	hook := &Hook{
+		conn:             writes,
+		appName:          "batch_test",
+		alwaysSentFields: logrus.Fields{},
+		BatchSize:        100,
+		BatchInterval:    20 * time.Millisecond,
+	}
+	for i := 0; i < 2; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case payload := <-writes:
+		if lines := bytes.Count(payload, []byte("\n")); lines != 2 {
+			t.Errorf("expected 2 newline-separated entries but got %d", lines)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the partial batch to be written after the interval")
+	}
+}
+
+func TestFireFatalConn(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	fatal := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "fatal_conn_test",
+		alwaysSentFields: logrus.Fields{},
+		BatchSize:        100,
+		BatchInterval:    time.Hour,
+		FatalConn:        fatal,
+	}
+	entry := &logrus.Entry{Message: "chatty", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if fatal.buff.Len() != 0 || conn.buff.Len() != 0 {
+		t.Fatal("expected the info entry to be batched")
+	}
+
+	entry = &logrus.Entry{Message: "crash", Data: logrus.Fields{}, Level: logrus.FatalLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(fatal.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "crash" {
+		t.Errorf("expected message to be '%s' but got '%s'", "crash", res["message"])
+	}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "chatty" {
+		t.Errorf("expected the batch to be flushed to the connection but got '%s'", res["message"])
+	}
+}
+
+func TestFireBatchPerLevel(t *testing.T) {
+	tt := []struct {
+		batchPerLevel bool
+		expected      [][]string
+	}{
+		{false, [][]string{{"info", "error", "info"}, {"info"}}},
+		{true, [][]string{{"info", "info", "info"}, {"error"}}},
+	}
+
+	for _, te := range tt {
+		writes := make(chanWriter, 10)
+		hook := &Hook{
+			conn:             writes,
+			appName:          "batch_test",
+			alwaysSentFields: logrus.Fields{},
+			BatchSize:        3,
+			BatchInterval:    20 * time.Millisecond,
+			BatchPerLevel:    te.batchPerLevel,
+		}
+		for _, level := range []logrus.Level{logrus.InfoLevel, logrus.ErrorLevel, logrus.InfoLevel, logrus.InfoLevel} {
+			entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: level}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, expected := range te.expected {
+			select {
+			case payload := <-writes:
+				var levels []string
+				dec := json.NewDecoder(bytes.NewReader(payload))
+				for dec.More() {
+					var res map[string]string
+					if err := dec.Decode(&res); err != nil {
+						t.Fatal(err)
+					}
+					levels = append(levels, res["level"])
+				}
+				if !reflect.DeepEqual(expected, levels) {
+					t.Errorf("expected batch levels to be '%v' but got '%v'", expected, levels)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected a batch of '%v' to be written", expected)
+			}
+		}
+	}
+}
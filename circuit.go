@@ -0,0 +1,58 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// circuit tracks consecutive failed dials, to stop dialing after too many of
+// them.
+type circuit struct {
+	failed int
+	last   time.Time
+}
+
+// dial calls dial, unless maxAttempts consecutive dials failed already and
+// the cooldown, if any, hasn't elapsed since the last one.
+func (c *circuit) dial(now time.Time, maxAttempts int, cooldown time.Duration, dial func() (io.Writer, error)) (io.Writer, error) {
+	if err := c.begin(now, maxAttempts, cooldown); err != nil {
+		return nil, err
+	}
+	conn, err := dial()
+	c.end(err)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// begin records a dial starting at now, unless refuse returns an error. The
+// caller dials and then calls end, possibly without the circuit's lock held
+// meanwhile.
+func (c *circuit) begin(now time.Time, maxAttempts int, cooldown time.Duration) error {
+	if err := c.refuse(now, maxAttempts, cooldown); err != nil {
+		return err
+	}
+	c.last = now
+	return nil
+}
+
+// end records the outcome of a dial started by begin.
+func (c *circuit) end(err error) {
+	if err != nil {
+		c.failed++
+	} else {
+		c.failed = 0
+	}
+}
+
+// refuse returns the error dial fails with right away, if any.
+func (c *circuit) refuse(now time.Time, maxAttempts int, cooldown time.Duration) error {
+	if maxAttempts > 0 && c.failed >= maxAttempts {
+		if cooldown <= 0 || now.Sub(c.last) < cooldown {
+			return fmt.Errorf("Gave up reconnecting after %d failed attempts", c.failed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// redacted replaces secrets in a HookConfig.
+const redacted = "[REDACTED]"
+
+// HookConfig is a snapshot of the settings in effect for a hook, defaults
+// applied and secrets redacted, e.g. for support bundles.
+type HookConfig struct {
+	Protocol  string `json:"protocol,omitempty"`
+	Address   string `json:"address,omitempty"`
+	AppName   string `json:"app_name"`
+	Formatter string `json:"formatter"`
+
+	// Framing is "newline", "length-prefix" or "content-length-trailer".
+	Framing       string        `json:"framing"`
+	BatchSize     int           `json:"batch_size,omitempty"`
+	BatchInterval time.Duration `json:"batch_interval,omitempty"`
+	PoolSize      int           `json:"pool_size,omitempty"`
+
+	Proxy        string `json:"proxy,omitempty"`
+	HTTPEndpoint string `json:"http_endpoint,omitempty"`
+	HTTPUsername string `json:"http_username,omitempty"`
+	HTTPPassword string `json:"http_password,omitempty"`
+	HTTPGzip     bool   `json:"http_gzip,omitempty"`
+	Syslog       bool   `json:"syslog,omitempty"`
+
+	MaxReconnectAttempts int           `json:"max_reconnect_attempts,omitempty"`
+	ReconnectCooldown    time.Duration `json:"reconnect_cooldown,omitempty"`
+	VerifyOnConnect      bool          `json:"verify_on_connect,omitempty"`
+	WarmStandby          bool          `json:"warm_standby,omitempty"`
+	Durable              bool          `json:"durable,omitempty"`
+	SpoolPath            string        `json:"spool_path,omitempty"`
+	SpoolInterval        time.Duration `json:"spool_interval,omitempty"`
+
+	Sampling           int           `json:"sampling,omitempty"`
+	DedupWindow        time.Duration `json:"dedup_window,omitempty"`
+	StartupQuietPeriod time.Duration `json:"startup_quiet_period,omitempty"`
+	PauseBufferSize    int           `json:"pause_buffer_size,omitempty"`
+	MaxFields          int           `json:"max_fields,omitempty"`
+	MaxPayloadBytes    int           `json:"max_payload_bytes,omitempty"`
+	Heartbeat          time.Duration `json:"heartbeat,omitempty"`
+	CloseTimeout       time.Duration `json:"close_timeout"`
+	StrictMode         bool          `json:"strict_mode,omitempty"`
+}
+
+// Config returns a snapshot of the settings in effect for the hook, with the
+// HTTP password and the password of the Proxy URL redacted.
+func (h *Hook) Config() HookConfig {
+	h.mu.Lock()
+	formatter := h.Formatter
+	syslog := h.syslog != nil
+	h.mu.Unlock()
+
+	c := HookConfig{
+		Protocol:             h.protocol,
+		Address:              h.address,
+		AppName:              h.appName,
+		Formatter:            fmt.Sprintf("%T", formatter),
+		Framing:              "newline",
+		BatchSize:            h.BatchSize,
+		BatchInterval:        h.BatchInterval,
+		PoolSize:             h.PoolSize,
+		Proxy:                redactURL(h.Proxy),
+		HTTPEndpoint:         redactURL(h.HTTPEndpoint),
+		HTTPUsername:         h.HTTPUsername,
+		HTTPGzip:             h.HTTPGzip,
+		Syslog:               syslog,
+		MaxReconnectAttempts: h.MaxReconnectAttempts,
+		ReconnectCooldown:    h.ReconnectCooldown,
+		VerifyOnConnect:      h.VerifyOnConnect,
+		WarmStandby:          h.WarmStandby,
+		Durable:              h.Durable,
+		SpoolPath:            h.SpoolPath,
+		SpoolInterval:        h.SpoolInterval,
+		Sampling:             h.Sampling,
+		DedupWindow:          h.DedupWindow,
+		StartupQuietPeriod:   h.StartupQuietPeriod,
+		PauseBufferSize:      h.PauseBufferSize,
+		MaxFields:            h.MaxFields,
+		MaxPayloadBytes:      h.MaxPayloadBytes,
+		Heartbeat:            h.Heartbeat,
+		CloseTimeout:         h.CloseTimeout,
+		StrictMode:           h.StrictMode,
+	}
+	if formatter == nil {
+		c.Formatter = fmt.Sprintf("%T", &LogstashFormatter{})
+	}
+	switch {
+	case h.LengthPrefixFraming:
+		c.Framing = "length-prefix"
+	case h.ContentLengthTrailer:
+		c.Framing = "content-length-trailer"
+	}
+	if c.BatchSize > 1 && c.BatchInterval <= 0 {
+		c.BatchInterval = defaultBatchInterval
+	}
+	if c.Durable && c.SpoolInterval <= 0 {
+		c.SpoolInterval = defaultSpoolInterval
+	}
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = defaultCloseTimeout
+	}
+	if h.HTTPPassword != "" {
+		c.HTTPPassword = redacted
+	}
+	return c
+}
+
+// redactURL returns the URL with its password, if any, replaced by "xxxxx".
+func redactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		// Whatever it holds may be secret.
+		return redacted
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,230 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// randInt63n and sleep are swapped in tests.
+var (
+	randInt63n = rand.Int63n
+	sleep      = time.Sleep
+)
+
+// redials reports whether the hook dials its own connections, because it was
+// created with an address or a connection factory.
+func (h *Hook) redials() bool {
+	return h.address != "" || h.connFactory != nil
+}
+
+// dial creates a new connection for the hook, writes the StreamHeader to it,
+// and verifies it if VerifyOnConnect is set.
+func (h *Hook) dial() (io.Writer, error) {
+	var conn io.Writer
+	var err error
+	if h.connFactory != nil {
+		conn, err = h.connFactory()
+	} else {
+		conn, err = h.dialAddress()
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = h.writeHeader(conn)
+	if err == nil && h.VerifyOnConnect {
+		err = verifyConn(conn, verifyTimeout)
+	}
+	if err != nil {
+		if closer, ok := conn.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialAddress dials the hook's address, through its Proxy if set.
+func (h *Hook) dialAddress() (net.Conn, error) {
+	if h.Proxy == "" {
+		return net.Dial(h.protocol, h.address)
+	}
+	switch h.protocol {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("Proxy requires a TCP protocol, got %q", h.protocol)
+	}
+	return dialSOCKS5(h.Proxy, h.address)
+}
+
+// verifyTimeout is how long a connection being verified must stay open.
+const verifyTimeout = 100 * time.Millisecond
+
+// checkTimeout is how long a supplied connection is checked for, which only
+// catches connections already closed on either end.
+const checkTimeout = 10 * time.Millisecond
+
+// verifyConn checks a network connection stays open for timeout, to catch
+// endpoints which accept connections and close them right away. It only
+// reads from the connection, so that nothing but entries is written to it.
+func verifyConn(conn io.Writer, timeout time.Duration) error {
+	c, ok := conn.(net.Conn)
+	if !ok {
+		return nil
+	}
+	c.SetReadDeadline(time.Now().Add(timeout))
+	defer c.SetReadDeadline(time.Time{})
+	// Logstash never writes back, so reading times out on a healthy
+	// connection.
+	_, err := c.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() || err == nil {
+		return nil
+	}
+	return fmt.Errorf("Failed to verify connection, %v", err)
+}
+
+// reconnect dials a new connection, unless MaxReconnectAttempts consecutive
+// dials failed already and the ReconnectCooldown, if any, hasn't elapsed
+// since the last one. h.mu must be held.
+func (h *Hook) reconnect() (io.Writer, error) {
+	conn, err := h.dials.dial(h.clock(), h.MaxReconnectAttempts, h.ReconnectCooldown, h.dial)
+	if err != nil {
+		h.logInternal(logrus.WarnLevel, "Failed to reconnect", logrus.Fields{"address": h.address, "error": err})
+		return nil, err
+	}
+	h.logInternal(logrus.DebugLevel, "Reconnected", logrus.Fields{"address": h.address})
+	return conn, nil
+}
+
+// jitterReconnect calls jitter if shipping an entry of the given level
+// reconnects the hook's connection.
+func (h *Hook) jitterReconnect(level logrus.Level) {
+	if h.ReconnectJitter <= 0 {
+		return
+	}
+	h.mu.Lock()
+	reconnecting := h.conn == nil && h.redials() && h.levelConn(level) == nil && h.syslog == nil && h.HTTPEndpoint == ""
+	h.mu.Unlock()
+	if reconnecting {
+		h.jitter()
+	}
+}
+
+// jitter sleeps for a random delay of up to ReconnectJitter ahead of a
+// reconnect, unless it would be refused anyway. It must be called without
+// h.mu or h.writeMu held, so that entries shipped to other writers, or
+// formatted meanwhile, aren't held up.
+func (h *Hook) jitter() {
+	if h.ReconnectJitter <= 0 {
+		return
+	}
+	h.mu.Lock()
+	err := h.dials.refuse(h.clock(), h.MaxReconnectAttempts, h.ReconnectCooldown)
+	h.mu.Unlock()
+	if err == nil {
+		sleep(time.Duration(randInt63n(int64(h.ReconnectJitter) + 1)))
+	}
+}
+
+// Reopen makes a hook which gave up reconnecting after MaxReconnectAttempts
+// try again, dialing its connection right away.
+func (h *Hook) Reopen() error {
+	h.mu.Lock()
+	h.dials.failed = 0
+	h.mu.Unlock()
+
+	_, dialed, err := h.connection()
+	if err != nil {
+		h.setHealthy(false)
+		return err
+	}
+	if dialed {
+		h.setHealthy(true)
+	}
+	return nil
+}
+
+// writeHeader writes the StreamHeader, if any, to a new connection.
+func (h *Hook) writeHeader(conn io.Writer) error {
+	if len(h.StreamHeader) == 0 {
+		return nil
+	}
+	return write(conn, h.StreamHeader)
+}
+
+// connection returns the hook's connection, dialing it first if the hook
+// dials its own connections and isn't connected.
+func (h *Hook) connection() (conn io.Writer, dialed bool, err error) {
+	h.mu.Lock()
+	conn, dialed, err = h.primaryConnection()
+	// A standby is only dialed alongside a live connection, not while the
+	// hook fails to dial or gave up dialing.
+	standby := err == nil && conn != nil && h.WarmStandby && h.redials() && h.standby == nil && !h.dialingStandby &&
+		h.dials.refuse(h.clock(), h.MaxReconnectAttempts, h.ReconnectCooldown) == nil
+	if standby {
+		h.dialingStandby = true
+	}
+	h.mu.Unlock()
+	if standby {
+		h.dialStandby()
+	}
+	return conn, dialed, err
+}
+
+// primaryConnection returns the hook's connection, dialing it if need be.
+// It must be called with h.mu held.
+func (h *Hook) primaryConnection() (io.Writer, bool, error) {
+	if h.conn == nil && h.redials() {
+		conn, err := h.reconnect()
+		if err != nil {
+			return nil, false, err
+		}
+		h.conn = conn
+		h.connClosed = false
+		h.headerSent = true
+		return conn, true, nil
+	}
+	// Connections the hook was created with get their header on first use.
+	if h.conn != nil && !h.headerSent {
+		if err := h.writeHeader(h.conn); err != nil {
+			return nil, false, err
+		}
+		h.headerSent = true
+	}
+	return h.conn, false, nil
+}
+
+// connWritten updates the state of the hook's connection after a write to it.
+// A failed connection is dropped, to be dialed again on the next Fire, if the
+// hook dials its own connections.
+func (h *Hook) connWritten(err error) {
+	h.mu.Lock()
+	if err != nil && h.redials() {
+		if closer, ok := h.conn.(io.Closer); ok {
+			closer.Close()
+		}
+		h.conn = nil
+		h.headerSent = false
+	}
+	h.mu.Unlock()
+
+	h.setHealthy(err == nil)
+}
+
+// setHealthy records whether the connection is healthy, calling
+// OnConnStateChange when that changes.
+func (h *Hook) setHealthy(healthy bool) {
+	h.mu.Lock()
+	changed := h.unhealthy == healthy
+	h.unhealthy = !healthy
+	onChange := h.OnConnStateChange
+	h.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(healthy)
+	}
+}
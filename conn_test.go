@@ -0,0 +1,538 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestOnConnStateChange(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	accepted := make(chan net.Conn, 10)
+	serve := func(ln net.Listener) {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}
+	go serve(ln)
+
+	var states []bool
+	hook := NewLazyHook("tcp", address, "conn_state_test")
+	hook.OnConnStateChange = func(healthy bool) {
+		states = append(states, healthy)
+	}
+	defer hook.Close()
+	fire := func() error {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		return hook.Fire(entry)
+	}
+
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 0 {
+		t.Fatalf("expected no state change while healthy but got '%v'", states)
+	}
+
+	// Kill the listener and the connection; writes fail once the reset
+	// reaches the client.
+	ln.Close()
+	(<-accepted).Close()
+	for i := 0; i < 100 && len(states) == 0; i++ {
+		fire()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(states) != 1 || states[0] != false {
+		t.Fatalf("expected the callback to fire with false but got '%v'", states)
+	}
+	if err := fire(); err == nil {
+		t.Fatal("expected re-dialing a dead listener to fail")
+	}
+	if len(states) != 1 {
+		t.Fatalf("expected no state change while unhealthy but got '%v'", states)
+	}
+
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serve(ln)
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[1] != true {
+		t.Errorf("expected the callback to fire with true after re-dialing but got '%v'", states)
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "interrupted system call" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// FlakyWriter fails its first writes with a temporary error.
+type FlakyWriter struct {
+	failures int
+	buff     bytes.Buffer
+}
+
+func (w *FlakyWriter) Write(b []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		return 0, temporaryError{}
+	}
+	return w.buff.Write(b)
+}
+
+func TestFireRetriesTemporaryErrors(t *testing.T) {
+	tt := []struct {
+		failures int
+		shipped  bool
+	}{
+		{1, true},
+		{maxWriteRetries, true},
+		{maxWriteRetries + 1, false},
+	}
+
+	for _, te := range tt {
+		w := &FlakyWriter{failures: te.failures}
+		var states []bool
+		hook := &Hook{
+			conn:              w,
+			appName:           "retry_test",
+			alwaysSentFields:  logrus.Fields{},
+			OnConnStateChange: func(healthy bool) { states = append(states, healthy) },
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		err := hook.Fire(entry)
+		if te.shipped {
+			if err != nil {
+				t.Errorf("expected the entry to ship after %d temporary errors but got '%v'", te.failures, err)
+			}
+			if len(states) != 0 {
+				t.Errorf("expected no state change but got '%v'", states)
+			}
+			var res map[string]string
+			if err := json.NewDecoder(&w.buff).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res["message"] != "hello world!" {
+				t.Errorf("expected message to be '%s' but got '%s'", "hello world!", res["message"])
+			}
+		} else if err == nil {
+			t.Errorf("expected Fire to fail after %d temporary errors", te.failures)
+		}
+		if hook.conn != w {
+			t.Error("expected the connection to be kept")
+		}
+	}
+}
+
+func TestNewHookWithConnFactory(t *testing.T) {
+	calls := 0
+	buff := bytes.NewBufferString("")
+	factory := func() (io.Writer, error) {
+		calls++
+		if calls == 1 {
+			return FailingWriter{}, nil
+		}
+		return buff, nil
+	}
+	hook, err := NewHookWithConnFactory(factory, "factory_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the factory to be called by the constructor but got %d calls", calls)
+	}
+	fire := func() error {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		return hook.Fire(entry)
+	}
+
+	if err := fire(); err == nil {
+		t.Fatal("expected the first write to fail")
+	}
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the factory to be called again to reconnect but got %d calls", calls)
+	}
+	var res map[string]interface{}
+	if err := json.NewDecoder(buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello world!" {
+		t.Errorf("expected message to be '%s' but got '%v'", "hello world!", res["message"])
+	}
+
+	if _, err := NewHookWithConnFactory(func() (io.Writer, error) {
+		return nil, errors.New("no endpoint")
+	}, "factory_test"); err == nil {
+		t.Error("expected the constructor to fail when the factory fails")
+	}
+}
+
+func TestFireStreamHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	header := []byte("LS\x00\x01")
+	hook := NewLazyHook("tcp", ln.Addr().String(), "header_test")
+	hook.StreamHeader = header
+	defer hook.Close()
+	fire := func() error {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		return hook.Fire(entry)
+	}
+	expectHeader := func(server net.Conn) {
+		got := make([]byte, len(header))
+		if _, err := io.ReadFull(server, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, header) {
+			t.Errorf("expected header to be '%q' but got '%q'", header, got)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(server).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != "hello world!" {
+			t.Errorf("expected message to be '%s' but got '%v'", "hello world!", res["message"])
+		}
+	}
+
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	server := <-accepted
+	expectHeader(server)
+
+	// Kill the connection; writes fail once the reset reaches the client, and
+	// the next Fire reconnects.
+	server.Close()
+	for i := 0; i < 100 && fire() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	server = <-accepted
+	defer server.Close()
+	expectHeader(server)
+}
+
+func TestFireStreamHeaderWithConn(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "header_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.StreamHeader = []byte("LS\x00\x01")
+	for i := 0; i < 2; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.HasPrefix(conn.buff.Bytes(), hook.StreamHeader) {
+		t.Errorf("expected output to start with the header but got '%q'", conn.buff.Bytes())
+	}
+	if n := bytes.Count(conn.buff.Bytes(), hook.StreamHeader); n != 1 {
+		t.Errorf("expected the header to be written once but got %d", n)
+	}
+}
+
+func TestFireMaxReconnectAttempts(t *testing.T) {
+	now := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	dials := 0
+	hook := &Hook{
+		connFactory: func() (io.Writer, error) {
+			dials++
+			return nil, errors.New("connection refused")
+		},
+		alwaysSentFields:     logrus.Fields{},
+		MaxReconnectAttempts: 3,
+		ReconnectCooldown:    time.Minute,
+		now:                  func() time.Time { return now },
+	}
+	fire := func() error {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		return hook.Fire(entry)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := fire(); err == nil {
+			t.Fatal("expected Fire to fail with an unreachable endpoint")
+		}
+	}
+	if dials != 3 {
+		t.Errorf("expected reconnecting to stop after %d attempts but got %d", 3, dials)
+	}
+
+	if err := hook.Reopen(); err == nil {
+		t.Fatal("expected Reopen to fail with an unreachable endpoint")
+	}
+	if dials != 4 {
+		t.Errorf("expected Reopen to dial again but got %d dials", dials)
+	}
+	fire()
+	fire()
+	if dials != 6 {
+		t.Errorf("expected Reopen to reset the attempts but got %d dials", dials)
+	}
+	fire()
+	if dials != 6 {
+		t.Errorf("expected reconnecting to stop again but got %d dials", dials)
+	}
+
+	now = now.Add(time.Minute)
+	fire()
+	if dials != 7 {
+		t.Errorf("expected a dial once the cooldown elapsed but got %d dials", dials)
+	}
+}
+
+func TestFireReconnectJitter(t *testing.T) {
+	var delays []time.Duration
+	randInt63n = rand.New(rand.NewSource(1)).Int63n
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() {
+		randInt63n = rand.Int63n
+		sleep = time.Sleep
+	}()
+
+	jitter := 100 * time.Millisecond
+	hook := &Hook{
+		connFactory: func() (io.Writer, error) {
+			return nil, errors.New("connection refused")
+		},
+		alwaysSentFields: logrus.Fields{},
+		ReconnectJitter:  jitter,
+	}
+	for i := 0; i < 1000; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err == nil {
+			t.Fatal("expected Fire to fail with an unreachable endpoint")
+		}
+	}
+
+	if len(delays) != 1000 {
+		t.Fatalf("expected a delay before each of %d dials but got %d", 1000, len(delays))
+	}
+	var min, max time.Duration = jitter, 0
+	for _, d := range delays {
+		if d < 0 || d > jitter {
+			t.Errorf("expected delay to be within [0, %v] but got '%v'", jitter, d)
+		}
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	// Delays spread over the whole range.
+	if min > jitter/10 || max < jitter*9/10 {
+		t.Errorf("expected delays to spread over [0, %v] but got [%v, %v]", jitter, min, max)
+	}
+}
+
+func TestFireReconnectJitterDoesNotBlockOtherEntries(t *testing.T) {
+	sleeping := make(chan struct{})
+	release := make(chan struct{})
+	sleep = func(d time.Duration) {
+		close(sleeping)
+		<-release
+	}
+	defer func() {
+		sleep = time.Sleep
+	}()
+
+	errorConn := make(chanWriter, 1)
+	hook := &Hook{
+		connFactory: func() (io.Writer, error) {
+			return nil, errors.New("connection refused")
+		},
+		alwaysSentFields: logrus.Fields{},
+		ReconnectJitter:  time.Second,
+		LevelConns:       map[logrus.Level]io.Writer{logrus.ErrorLevel: errorConn},
+	}
+	fired := make(chan error, 1)
+	go func() {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		fired <- hook.Fire(entry)
+	}()
+	<-sleeping
+
+	entry := &logrus.Entry{Message: "failed!", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errorConn:
+	default:
+		t.Error("expected the Error entry to ship while the Info one waits to reconnect")
+	}
+	close(release)
+	if err := <-fired; err == nil {
+		t.Error("expected Fire to fail with an unreachable endpoint")
+	}
+}
+
+func TestNewVerifiedHook(t *testing.T) {
+	for _, closing := range []bool{true, false} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		accepted := make(chan net.Conn, 1)
+		go func(closing bool) {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if closing {
+				conn.Close()
+				return
+			}
+			accepted <- conn
+		}(closing)
+
+		hook, err := NewVerifiedHook("tcp", ln.Addr().String(), "verified_test")
+		if closing && err == nil {
+			t.Error("expected verification to fail when the endpoint closes the connection")
+		}
+		if !closing {
+			if err != nil {
+				t.Fatal(err)
+			}
+			hook.Close()
+			(<-accepted).Close()
+		}
+		ln.Close()
+	}
+}
+
+func TestNewCheckedHookWithConn(t *testing.T) {
+	tt := []struct {
+		name    string
+		closing func(client, server net.Conn)
+		healthy bool
+	}{
+		{"open", func(client, server net.Conn) {}, true},
+		{"closed", func(client, server net.Conn) { client.Close() }, false},
+		{"closed by the endpoint", func(client, server net.Conn) { server.Close() }, false},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	for _, te := range tt {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		te.closing(client, server)
+
+		hook, err := NewCheckedHookWithConn(client, "checked_test")
+		if te.healthy && err != nil {
+			t.Errorf("expected the %s connection to pass the check but got '%v'", te.name, err)
+		}
+		if !te.healthy && err == nil {
+			t.Errorf("expected the %s connection to fail the check", te.name)
+		}
+		if hook != nil {
+			hook.Close()
+		}
+		client.Close()
+		if te.healthy {
+			if b, _ := ioutil.ReadAll(server); len(b) != 0 {
+				t.Errorf("expected the check to write nothing but got '%q'", b)
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestFireAttemptCountField(t *testing.T) {
+	tt := []struct {
+		failures int
+		attempt  float64
+	}{
+		{0, 1},
+		{1, 2},
+		{maxWriteRetries, maxWriteRetries + 1},
+	}
+
+	for _, te := range tt {
+		w := &FlakyWriter{failures: te.failures}
+		hook := &Hook{
+			conn:              w,
+			appName:           "attempt_test",
+			alwaysSentFields:  logrus.Fields{},
+			AttemptCountField: "attempt",
+			SequenceField:     "seq",
+			RingBufferSize:    3,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(&w.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["attempt"] != te.attempt {
+			t.Errorf("expected attempt to be '%v' after %d temporary errors but got '%v'", te.attempt, te.failures, res["attempt"])
+		}
+		if res["seq"] != float64(1) {
+			t.Errorf("expected seq to be '%v' but got '%v'", 1, res["seq"])
+		}
+		if w.buff.Len() != 0 {
+			t.Errorf("expected a single entry to be written but got '%s' more", w.buff.String())
+		}
+		recent := hook.Recent()
+		if len(recent) != 1 || recent[0]["attempt"] != te.attempt {
+			t.Errorf("expected the delivered attempt alone to be recent but got '%v'", recent)
+		}
+	}
+}
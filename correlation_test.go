@@ -0,0 +1,45 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireCorrelationField(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tt := []struct {
+		generator func() string
+		data      logrus.Fields
+		match     func(string) bool
+	}{
+		{nil, logrus.Fields{}, uuid.MatchString},
+		{func() string { return "generated" }, logrus.Fields{}, func(id string) bool { return id == "generated" }},
+		{nil, logrus.Fields{"correlation.id": "existing"}, func(id string) bool { return id == "existing" }},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:                 conn,
+			appName:              "correlation_test",
+			alwaysSentFields:     logrus.Fields{},
+			CorrelationField:     "correlation.id",
+			CorrelationGenerator: te.generator,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: te.data, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if !te.match(res["correlation.id"]) {
+			t.Errorf("unexpected correlation.id '%s'", res["correlation.id"])
+		}
+	}
+}
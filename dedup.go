@@ -0,0 +1,122 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultDedupSize = 1000
+
+// repeatedEntry is an entry held back while its duplicates are counted.
+type repeatedEntry struct {
+	entry *logrus.Entry
+	count int
+}
+
+// dedup reports whether the entry, whose dedupKey is key, is held back as a
+// duplicate. The first occurrence of an entry starts a window at the end of
+// which a single entry with the total count is shipped.
+func (h *Hook) dedup(entry *logrus.Entry, key uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r, ok := h.pending[key]; ok {
+		r.count++
+		return true
+	}
+
+	size := h.DedupSize
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	if len(h.pending) >= size {
+		return false
+	}
+	if h.pending == nil {
+		h.pending = make(map[uint64]*repeatedEntry)
+	}
+	h.pending[key] = &repeatedEntry{entry: h.holdCopy(entry), count: 1}
+	time.AfterFunc(h.DedupWindow, func() {
+		h.reportError(h.flushRepeated(key))
+	})
+	return true
+}
+
+// flushRepeated ships the entry held back under key along with its count.
+func (h *Hook) flushRepeated(key uint64) error {
+	h.mu.Lock()
+	r, ok := h.pending[key]
+	delete(h.pending, key)
+	h.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return h.shipRepeated(r)
+}
+
+// shipRepeated ships a held back entry along with its count.
+func (h *Hook) shipRepeated(r *repeatedEntry) error {
+	r.entry.Data["@repeat_count"] = r.count
+	return h.ship(r.entry)
+}
+
+// collapse holds the entry back while it repeats the previous one, with the
+// same message and level, counting it instead. The run of the previous entry
+// ships once a different entry comes.
+func (h *Hook) collapse(entry *logrus.Entry) error {
+	h.mu.Lock()
+	if r := h.run; r != nil && r.entry.Message == entry.Message && r.entry.Level == entry.Level {
+		r.count++
+		h.mu.Unlock()
+		return nil
+	}
+	previous := h.run
+	h.run = &repeatedEntry{entry: h.holdCopy(entry), count: 1}
+	h.mu.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+	return h.shipRepeated(previous)
+}
+
+// flushRun ships the run of the last entry held back by collapse.
+func (h *Hook) flushRun() error {
+	h.mu.Lock()
+	r := h.run
+	h.run = nil
+	h.mu.Unlock()
+
+	if r == nil {
+		return nil
+	}
+	return h.shipRepeated(r)
+}
+
+// dedupKey hashes the message, level and fields of the entry, before the hook
+// adds its own.
+func dedupKey(entry *logrus.Entry) uint64 {
+	hash := fnv.New64a()
+	// fmt prints maps with sorted keys, so equal fields hash equally.
+	fmt.Fprintf(hash, "%s\x00%s\x00%v", entry.Level, entry.Message, entry.Data)
+	return hash.Sum64()
+}
+
+// copyEntry returns a copy of the entry whose fields are safe to modify.
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	return &logrus.Entry{
+		Logger:  entry.Logger,
+		Data:    data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+	}
+}
@@ -0,0 +1,196 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// chanWriter hands every write over a channel, for writes made from other
+// goroutines.
+type chanWriter chan []byte
+
+func (c chanWriter) Write(b []byte) (int, error) {
+	c <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func TestFireDedupWindow(t *testing.T) {
+	writes := make(chanWriter, 10)
+	hook := &Hook{
+		appName:          "dedup_test",
+		alwaysSentFields: logrus.Fields{},
+		LevelConns:       map[logrus.Level]io.Writer{logrus.ErrorLevel: writes},
+		DedupWindow:      50 * time.Millisecond,
+	}
+	for i := 0; i < 100; i++ {
+		entry := &logrus.Entry{
+			Message: "connection refused",
+			Data:    logrus.Fields{"attempt": "same"},
+			Level:   logrus.ErrorLevel,
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	var res map[string]interface{}
+	select {
+	case b := <-writes:
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the aggregated entry to ship once the window closed")
+	}
+	if res["@repeat_count"] != float64(100) {
+		t.Errorf("expected @repeat_count to be '%v' but got '%v'", 100, res["@repeat_count"])
+	}
+	if res["message"] != "connection refused" {
+		t.Errorf("expected message to be '%s' but got '%v'", "connection refused", res["message"])
+	}
+	select {
+	case b := <-writes:
+		t.Errorf("expected a single aggregated entry but also got '%s'", b)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFireDedupWindowReportsErrors(t *testing.T) {
+	errs := make(chan error, 1)
+	hook := &Hook{
+		conn:             FailingWriter{},
+		appName:          "dedup_test",
+		alwaysSentFields: logrus.Fields{},
+		DedupWindow:      10 * time.Millisecond,
+		OnError:          func(err error, suppressed int) { errs <- err },
+	}
+	entry := &logrus.Entry{Message: "connection refused", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected OnError to get the error of the aggregated entry")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the failed write of the aggregated entry to be reported")
+	}
+}
+
+func TestFireDedupWindowIgnoresGeneratedFields(t *testing.T) {
+	writes := make(chanWriter, 10)
+	hook := &Hook{
+		appName:            "dedup_test",
+		alwaysSentFields:   logrus.Fields{},
+		LevelConns:         map[logrus.Level]io.Writer{logrus.ErrorLevel: writes},
+		DedupWindow:        50 * time.Millisecond,
+		CorrelationField:   "correlation_id",
+		IncludeGoroutineID: true,
+		MonotonicField:     "monotonic_ns",
+	}
+	for i := 0; i < 5; i++ {
+		entry := &logrus.Entry{Message: "connection refused", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	var res map[string]interface{}
+	select {
+	case b := <-writes:
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the aggregated entry to ship once the window closed")
+	}
+	if res["@repeat_count"] != float64(5) {
+		t.Errorf("expected @repeat_count to be '%v' but got '%v'", 5, res["@repeat_count"])
+	}
+	if res["correlation_id"] == nil {
+		t.Error("expected the aggregated entry to carry a correlation id")
+	}
+}
+
+func TestFireFatalFlushes(t *testing.T) {
+	for _, level := range []logrus.Level{logrus.FatalLevel, logrus.PanicLevel} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "fatal_test",
+			alwaysSentFields: logrus.Fields{},
+			DedupWindow:      time.Hour,
+		}
+		held := &logrus.Entry{Message: "held", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(held); err != nil {
+			t.Fatal(err)
+		}
+		if conn.buff.Len() != 0 {
+			t.Fatal("expected the entry to be held back")
+		}
+		fatal := &logrus.Entry{Message: "crash", Data: logrus.Fields{}, Level: level}
+		if err := hook.Fire(fatal); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := json.NewDecoder(conn.buff)
+		for _, expected := range []string{"held", "crash"} {
+			var res map[string]interface{}
+			if err := dec.Decode(&res); err != nil {
+				t.Fatalf("expected '%s' to be written within Fire: %v", expected, err)
+			}
+			if res["message"] != expected {
+				t.Errorf("expected message to be '%s' but got '%v'", expected, res["message"])
+			}
+		}
+	}
+}
+
+func TestFireCollapseConsecutive(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:                conn,
+		appName:             "collapse_test",
+		alwaysSentFields:    logrus.Fields{},
+		CollapseConsecutive: true,
+		// Runs are told apart by message and level alone.
+		CorrelationField: "correlation_id",
+	}
+	for _, message := range []string{"A", "A", "A", "B", "A"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		message string
+		count   float64
+	}{
+		{"A", 3},
+		{"B", 1},
+		{"A", 1},
+	}
+	dec := json.NewDecoder(conn.buff)
+	for _, e := range expected {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != e.message || res["@repeat_count"] != e.count {
+			t.Errorf("expected %s with count %v but got %v with count %v", e.message, e.count, res["message"], res["@repeat_count"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more entries")
+	}
+}
@@ -0,0 +1,50 @@
+package logrus_logstash
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Derive creates a new hook shipping over the connection of h, with its own
+// app name and fields, e.g. for another logger, instead of opening another
+// connection to the same Logstash instance. Writes of h and all hooks derived
+// from it are serialized, and reconnect the shared connection as h does.
+// Entries are routed by the LevelConns, syslog or HTTPEndpoint of h like its
+// own. Closing a derived hook leaves the connection open.
+func (h *Hook) Derive(appName string, alwaysSentFields logrus.Fields) *Hook {
+	return &Hook{
+		conn:             sharedWriter{h: h},
+		appName:          appName,
+		alwaysSentFields: alwaysSentFields,
+		hookOnlyPrefix:   h.hookOnlyPrefix,
+	}
+}
+
+// sharedWriter writes to the writer of a hook for level, under its write
+// lock, so that every payload goes through whole.
+type sharedWriter struct {
+	h     *Hook
+	level logrus.Level
+}
+
+func (w sharedWriter) Write(b []byte) (int, error) {
+	w.h.writeMu.Lock()
+	defer w.h.writeMu.Unlock()
+
+	writer, isConn, err := w.h.writerFor(w.level)
+	if err != nil {
+		return 0, err
+	}
+	if writer == nil {
+		return 0, errors.New("Failed to write, the hook derived from has no connection")
+	}
+	err = write(writer, b)
+	if isConn {
+		w.h.connWritten(err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
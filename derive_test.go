@@ -0,0 +1,93 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDerive(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := []*Hook{
+		hook.Derive("api", logrus.Fields{"component": "api"}),
+		hook.Derive("worker", logrus.Fields{"component": "worker"}),
+	}
+
+	const perHook = 100
+	var wg sync.WaitGroup
+	for _, derived := range hooks {
+		wg.Add(1)
+		go func(derived *Hook) {
+			defer wg.Done()
+			for i := 0; i < perHook; i++ {
+				entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+				if err := derived.Fire(entry); err != nil {
+					t.Error(err)
+				}
+			}
+		}(derived)
+	}
+	wg.Wait()
+	for _, derived := range hooks {
+		if err := derived.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts := map[string]int{}
+	dec := json.NewDecoder(conn.buff)
+	for dec.More() {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["type"] != res["component"] {
+			t.Errorf("expected type and component to match but got '%s' and '%s'", res["type"], res["component"])
+		}
+		counts[res["type"]]++
+	}
+	for _, appName := range []string{"api", "worker"} {
+		if counts[appName] != perHook {
+			t.Errorf("expected %d entries from %s but got %d", perHook, appName, counts[appName])
+		}
+	}
+}
+
+func TestDeriveRoutesLikeParent(t *testing.T) {
+	posts := make(chan map[string]string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			t.Error(err)
+		}
+		posts <- res
+	}))
+	defer server.Close()
+
+	derived := NewHTTPHook(server.URL, "parent").Derive("api", logrus.Fields{})
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := derived.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("expected the entry to be POSTed once but got '%d' requests", len(posts))
+	}
+	if res := <-posts; res["type"] != "api" {
+		t.Errorf("expected type to be '%s' but got '%s'", "api", res["type"])
+	}
+
+	derived = NewFilterHook().Derive("api", logrus.Fields{})
+	entry = &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := derived.Fire(entry); err == nil {
+		t.Error("expected Fire to fail when the hook derived from has no connection")
+	}
+}
@@ -0,0 +1,40 @@
+package logrus_logstash
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// DropReason tells why the hook dropped an entry.
+type DropReason int
+
+const (
+	// DropFiltered drops entries the Filter returns false for.
+	DropFiltered DropReason = iota
+	// DropSampled drops entries left out by Sampling.
+	DropSampled
+	// DropBufferFull drops entries held back, while paused or during the
+	// StartupQuietPeriod, beyond the size of the buffer holding them.
+	DropBufferFull
+)
+
+func (r DropReason) String() string {
+	switch r {
+	case DropFiltered:
+		return "filtered"
+	case DropSampled:
+		return "sampled"
+	case DropBufferFull:
+		return "buffer full"
+	default:
+		return "unknown"
+	}
+}
+
+// drop counts the entry as dropped, and hands it to OnDrop if set.
+func (h *Hook) drop(entry *logrus.Entry, reason DropReason) {
+	h.countDropped()
+	h.logInternal(logrus.DebugLevel, "Dropped entry", logrus.Fields{"reason": reason.String(), "entry_message": entry.Message})
+	if h.OnDrop != nil {
+		h.OnDrop(entry, reason)
+	}
+}
@@ -0,0 +1,57 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireOnDrop(t *testing.T) {
+	var dropped []string
+	onDrop := func(entry *logrus.Entry, reason DropReason) {
+		dropped = append(dropped, entry.Message+":"+reason.String())
+	}
+	newHook := func() *Hook {
+		return &Hook{
+			conn:             ConnMock{buff: bytes.NewBufferString("")},
+			alwaysSentFields: logrus.Fields{},
+			OnDrop:           onDrop,
+		}
+	}
+	fire := func(hook *Hook, message string) {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filtered := newHook()
+	filtered.Filter = func(entry *logrus.Entry) bool { return entry.Message != "health" }
+	fire(filtered, "health")
+	fire(filtered, "request")
+
+	sampled := newHook()
+	sampled.Sampling = 2
+	fire(sampled, "first")
+	fire(sampled, "second")
+
+	paused := newHook()
+	paused.PauseBufferSize = 1
+	paused.Pause()
+	fire(paused, "held")
+	fire(paused, "overflow")
+
+	quiet := newHook()
+	quiet.StartupQuietPeriod = time.Hour
+	quiet.StartupBufferSize = 1
+	fire(quiet, "startup")
+	fire(quiet, "flood")
+
+	expected := []string{"health:filtered", "second:sampled", "overflow:buffer full", "flood:buffer full"}
+	if !reflect.DeepEqual(expected, dropped) {
+		t.Errorf("expected dropped entries to be '%v' but got '%v'", expected, dropped)
+	}
+}
@@ -0,0 +1,18 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// envelopeFields returns the fields of the EnvelopeJSON, parsed once.
+func (h *Hook) envelopeFields() (logrus.Fields, error) {
+	h.envelopeOnce.Do(func() {
+		if err := json.Unmarshal(h.EnvelopeJSON, &h.envelope); err != nil {
+			h.envelopeErr = fmt.Errorf("Failed to parse envelope JSON, %v", err)
+		}
+	})
+	return h.envelope, h.envelopeErr
+}
@@ -0,0 +1,82 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireEnvelopeJSON(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "envelope_test",
+		alwaysSentFields: logrus.Fields{"team": "payments"},
+		EnvelopeJSON:     []byte(`{"team": "platform", "region": "eu", "owner": "ops"}`),
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{"owner": "mick"}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"team": "payments", "region": "eu", "owner": "mick"}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%s' but got '%s'", k, v, res[k])
+		}
+	}
+}
+
+func TestFireEnvelopeJSONInvalid(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "envelope_test",
+		alwaysSentFields: logrus.Fields{},
+		EnvelopeJSON:     []byte(`{"team": `),
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Error("expected Fire to fail with an invalid envelope")
+	}
+}
+
+func TestFireResourceAttributes(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "resource_test",
+		alwaysSentFields: logrus.Fields{"service.namespace": "payments"},
+		ResourceAttributes: map[string]string{
+			"service.name":           "billing",
+			"service.namespace":      "shop",
+			"deployment.environment": "production",
+		},
+	}
+	data := logrus.Fields{"deployment.environment": "canary"}
+	entry := &logrus.Entry{Message: "hello world!", Data: data, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"service.name":           "billing",
+		"service.namespace":      "payments",
+		"deployment.environment": "canary",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%s' but got '%s'", k, v, res[k])
+		}
+	}
+}
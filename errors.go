@@ -0,0 +1,35 @@
+package logrus_logstash
+
+import (
+	"fmt"
+)
+
+// reportError throttles the errors of Fire to one per ErrorInterval, and hands
+// them to OnError if set. It returns the error, if any, Fire returns to logrus.
+func (h *Hook) reportError(err error) error {
+	if err == nil || (h.ErrorInterval <= 0 && h.OnError == nil) {
+		return err
+	}
+
+	h.mu.Lock()
+	now := h.clock()
+	if h.ErrorInterval > 0 && !h.lastError.IsZero() && now.Sub(h.lastError) < h.ErrorInterval {
+		h.suppressedErrors++
+		h.mu.Unlock()
+		return nil
+	}
+	suppressed := h.suppressedErrors
+	h.lastError = now
+	h.suppressedErrors = 0
+	onError := h.OnError
+	h.mu.Unlock()
+
+	if onError != nil {
+		onError(err, suppressed)
+		return nil
+	}
+	if suppressed > 0 {
+		return fmt.Errorf("%v (%d similar errors suppressed)", err, suppressed)
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package logrus_logstash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireThrottlesErrors(t *testing.T) {
+	now := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	var suppressed []int
+	hook := &Hook{
+		conn:             FailingWriter{},
+		alwaysSentFields: logrus.Fields{},
+		ErrorInterval:    30 * time.Second,
+		OnError: func(err error, n int) {
+			suppressed = append(suppressed, n)
+		},
+		now: func() time.Time { return now },
+	}
+
+	for i := 0; i < 100; i++ {
+		entry := &logrus.Entry{Message: "fail", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Errorf("expected errors to go to OnError but got '%v'", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	expected := []int{0, 29, 29, 29}
+	if len(suppressed) != len(expected) {
+		t.Fatalf("expected OnError to be called %d times but got %d", len(expected), len(suppressed))
+	}
+	for i, n := range expected {
+		if suppressed[i] != n {
+			t.Errorf("expected call %d to report %d suppressed errors but got %d", i, n, suppressed[i])
+		}
+	}
+}
+
+func TestFireThrottlesReturnedErrors(t *testing.T) {
+	now := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	hook := &Hook{
+		conn:             FailingWriter{},
+		alwaysSentFields: logrus.Fields{},
+		ErrorInterval:    time.Minute,
+		now:              func() time.Time { return now },
+	}
+	fire := func() error {
+		return hook.Fire(&logrus.Entry{Message: "fail", Data: logrus.Fields{}, Level: logrus.ErrorLevel})
+	}
+
+	if err := fire(); err == nil || err.Error() != "write failed" {
+		t.Errorf("expected the first error to be returned but got '%v'", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := fire(); err != nil {
+			t.Errorf("expected error to be suppressed but got '%v'", err)
+		}
+	}
+	now = now.Add(time.Minute)
+	expected := "write failed (5 similar errors suppressed)"
+	if err := fire(); err == nil || err.Error() != expected {
+		t.Errorf("expected error to be '%s' but got '%v'", expected, err)
+	}
+}
+
+func TestFireStrictMode(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		hook := &Hook{conn: FailingWriter{}, alwaysSentFields: logrus.Fields{}, StrictMode: strict}
+		var err error
+		recovered := func() (recovered interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			err = hook.Fire(&logrus.Entry{Message: "fail", Data: logrus.Fields{}, Level: logrus.ErrorLevel})
+			return nil
+		}()
+		if strict && (recovered == nil || recovered.(error).Error() != "write failed") {
+			t.Errorf("expected Fire to panic with the write error but got '%v'", recovered)
+		}
+		if !strict && (recovered != nil || err == nil) {
+			t.Errorf("expected Fire to return the write error but got '%v' and panicked with '%v'", err, recovered)
+		}
+	}
+}
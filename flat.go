@@ -0,0 +1,95 @@
+package logrus_logstash
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendFlatJSON appends data as a JSON object, and a newline, to buf if all
+// its values are strings, reporting whether it did. The output is identical
+// to that of json.Marshal, which it is much faster than, since it needs no
+// reflection.
+func appendFlatJSON(buf []byte, data logrus.Fields) ([]byte, bool) {
+	keys := make([]string, 0, len(data))
+	for k, v := range data {
+		if _, ok := v.(string); !ok {
+			return buf, false
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	start := len(buf)
+	buf = append(buf, '{')
+	for i, k := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var ok bool
+		if buf, ok = appendJSONString(buf, k); !ok {
+			return buf[:start], false
+		}
+		buf = append(buf, ':')
+		if buf, ok = appendJSONString(buf, data[k].(string)); !ok {
+			return buf[:start], false
+		}
+	}
+	return append(buf, '}', '\n'), true
+}
+
+// appendJSONString appends s as a JSON string escaped like encoding/json does,
+// reporting whether it did. Strings holding invalid UTF-8 or control
+// characters other than newlines, carriage returns and tabs, whose escaping
+// differs across Go versions, are left to encoding/json.
+func appendJSONString(buf []byte, s string) ([]byte, bool) {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			var esc string
+			switch c {
+			case '"':
+				esc = `\"`
+			case '\\':
+				esc = `\\`
+			case '\n':
+				esc = `\n`
+			case '\r':
+				esc = `\r`
+			case '\t':
+				esc = `\t`
+			case '<', '>', '&':
+				esc = `\u00` + string(hexDigits[c>>4]) + string(hexDigits[c&0xf])
+			default:
+				if c < 0x20 {
+					return buf, false
+				}
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, esc...)
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return buf, false
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\u202`...)
+			buf = append(buf, hexDigits[r&0xf])
+			start = i + size
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"'), true
+}
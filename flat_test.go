@@ -0,0 +1,80 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAppendFlatJSON(t *testing.T) {
+	tt := []struct {
+		data logrus.Fields
+		flat bool
+	}{
+		{logrus.Fields{}, true},
+		{logrus.Fields{"message": "hello world!", "level": "info"}, true},
+		{logrus.Fields{"quote": `say "hi" \ bye`, "html": "<a href='x'>&</a>"}, true},
+		{logrus.Fields{"lines": "one\ntwo\r\n\tthree", "unicode": "héllo wörld ✓ 日本"}, true},
+		{logrus.Fields{"separators": "a\u2028b\u2029c", "ключ": "значение"}, true},
+		{logrus.Fields{"bell": "ding\a"}, false},
+		{logrus.Fields{"invalid": "bad \xff byte"}, false},
+		{logrus.Fields{"count": 3}, false},
+		{logrus.Fields{"nested": map[string]string{"a": "b"}}, false},
+	}
+
+	for _, te := range tt {
+		b, ok := appendFlatJSON(nil, te.data)
+		if ok != te.flat {
+			t.Errorf("expected %v to be flat '%v' but got '%v'", te.data, te.flat, ok)
+		}
+		if !ok {
+			continue
+		}
+		expected, err := json.Marshal(te.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(expected)+"\n" {
+			t.Errorf("expected '%s' but got '%s'", expected, b)
+		}
+	}
+}
+
+var benchmarkEntry = &logrus.Entry{
+	Message: "request served",
+	Data: logrus.Fields{
+		"method":  "GET",
+		"path":    "/api/v1/users",
+		"status":  "200",
+		"user":    "alice",
+		"request": "2f1c9a7e-5b4d-4c3a-9e8f-1a2b3c4d5e6f",
+	},
+	Time:  time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+	Level: logrus.InfoLevel,
+}
+
+func BenchmarkLogstashFormatterFlat(b *testing.B) {
+	f := LogstashFormatter{Type: "bench"}
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Format(benchmarkEntry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogstashFormatterReflect(b *testing.B) {
+	// An int value takes the entry off the fast path.
+	entry := *benchmarkEntry
+	entry.Data = logrus.Fields{"count": 1}
+	for k, v := range benchmarkEntry.Data {
+		entry.Data[k] = v
+	}
+	f := LogstashFormatter{Type: "bench"}
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Format(&entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package logrus_logstash
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	// The nested values of decoded JSON, sent as interface values
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// GobFormatter formats entries as the logstash map encoded with
+// `encoding/gob`, for Go relays in front of Logstash which have no use for
+// JSON. Every entry is a self-contained gob stream, followed by a newline
+// for framing to strip, read back by a GobDecoder.
+type GobFormatter struct {
+	// Logstash formats the map encoded for entries. Defaults to a
+	// LogstashFormatter.
+	Logstash *LogstashFormatter
+}
+
+// Format formats the entry as a gob stream of its logstash map.
+func (f *GobFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	lf := f.Logstash
+	if lf == nil {
+		lf = &LogstashFormatter{}
+	}
+	b, err := lf.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip through JSON so that the map only holds the types the
+	// relay would otherwise decode
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal fields from JSON, %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		return nil, fmt.Errorf("Failed to encode fields to gob, %v", err)
+	}
+	// Framing strips the trailing newline of entries, which must not be
+	// the last byte of the stream
+	return append(buf.Bytes(), '\n'), nil
+}
+
+// GobDecoder reads the logstash maps of the entries formatted by a
+// GobFormatter, e.g. on the relay side of the connection.
+type GobDecoder struct {
+	r *bufio.Reader
+}
+
+// NewGobDecoder returns a GobDecoder reading from r.
+func NewGobDecoder(r io.Reader) *GobDecoder {
+	return &GobDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the logstash map of the next entry. It returns io.EOF once
+// there are no more entries.
+func (d *GobDecoder) Decode() (map[string]interface{}, error) {
+	// Every entry is its own stream, resending its types, so it needs its
+	// own decoder
+	var m map[string]interface{}
+	if err := gob.NewDecoder(d.r).Decode(&m); err != nil {
+		return nil, err
+	}
+	// The newline following the entry, unless framing stripped it. Streams
+	// start with the definition of the map type, never with a newline.
+	if b, err := d.r.ReadByte(); err == nil && b != '\n' {
+		d.r.UnreadByte()
+	}
+	return m, nil
+}
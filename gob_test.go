@@ -0,0 +1,109 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestGobFormatterRoundTrip(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "gob_test",
+		alwaysSentFields: logrus.Fields{},
+		Formatter:        &GobFormatter{Logstash: &LogstashFormatter{Type: "gob_test"}},
+	}
+
+	at := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	entries := []*logrus.Entry{
+		{Message: "one", Data: logrus.Fields{"user": "alice", "count": 3}, Time: at, Level: logrus.InfoLevel},
+		{Message: "two", Data: logrus.Fields{"tags": []string{"a", "b"}, "req": map[string]interface{}{"ok": true}}, Time: at, Level: logrus.WarnLevel},
+	}
+	for _, entry := range entries {
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"@version":   "1",
+			"@timestamp": "2017-05-01T10:00:00Z",
+			"message":    "one",
+			"level":      "info",
+			"type":       "gob_test",
+			"user":       "alice",
+			"count":      float64(3),
+		},
+		{
+			"@version":   "1",
+			"@timestamp": "2017-05-01T10:00:00Z",
+			"message":    "two",
+			"level":      "warning",
+			"type":       "gob_test",
+			"tags":       []interface{}{"a", "b"},
+			"req":        map[string]interface{}{"ok": true},
+		},
+	}
+	dec := NewGobDecoder(conn.buff)
+	for _, e := range expected {
+		m, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e, m) {
+			t.Errorf("expected fields to be '%v' but got '%v'", e, m)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected error to be '%v' but got '%v'", io.EOF, err)
+	}
+}
+
+func TestGobFormatterFraming(t *testing.T) {
+	for _, prefixed := range []bool{false, true} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:                conn,
+			appName:             "gob_test",
+			alwaysSentFields:    logrus.Fields{},
+			Formatter:           &GobFormatter{},
+			LengthPrefixFraming: prefixed,
+		}
+		const entries = 50
+		for i := 0; i < entries; i++ {
+			entry := &logrus.Entry{Message: "line\n", Data: logrus.Fields{"i": i}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dec := NewGobDecoder(conn.buff)
+		for i := 0; i < entries; i++ {
+			if prefixed {
+				var header [4]byte
+				if _, err := io.ReadFull(conn.buff, header[:]); err != nil {
+					t.Fatal(err)
+				}
+				payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+				if _, err := io.ReadFull(conn.buff, payload); err != nil {
+					t.Fatal(err)
+				}
+				dec = NewGobDecoder(bytes.NewReader(payload))
+			}
+			m, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("expected entry %d to decode but got '%v'", i, err)
+			}
+			if m["message"] != "line\n" || m["i"] != float64(i) {
+				t.Errorf("expected entry %d to be 'line\\n' but got '%v'", i, m)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package logrus_logstash
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startHeartbeat starts shipping heartbeat entries every Heartbeat, until
+// Close is called.
+func (h *Hook) startHeartbeat() {
+	h.heartbeatOnce.Do(func() {
+		h.goBackground(func(done <-chan struct{}) {
+			ticker := time.NewTicker(h.Heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					h.reportError(h.heartbeat())
+				case <-done:
+					return
+				}
+			}
+		})
+	})
+}
+
+// heartbeat ships a heartbeat entry, carrying the hook's own fields.
+func (h *Hook) heartbeat() error {
+	entry := &logrus.Entry{Message: "heartbeat", Data: logrus.Fields{}, Time: time.Now(), Level: logrus.InfoLevel}
+	if err := h.addContextFields(entry); err != nil {
+		return err
+	}
+	return h.ship(entry)
+}
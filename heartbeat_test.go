@@ -0,0 +1,113 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireHeartbeat(t *testing.T) {
+	writes := make(chanWriter, 100)
+	hook := &Hook{
+		conn:             writes,
+		appName:          "heartbeat_test",
+		alwaysSentFields: logrus.Fields{"service": "billing"},
+		Heartbeat:        10 * time.Millisecond,
+	}
+	entry := &logrus.Entry{Message: "started", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	heartbeats := 0
+	timeout := time.After(time.Second)
+	for heartbeats < 2 {
+		select {
+		case b := <-writes:
+			var res map[string]string
+			if err := json.Unmarshal(b, &res); err != nil {
+				t.Fatal(err)
+			}
+			if res["message"] != "heartbeat" {
+				continue
+			}
+			if res["service"] != "billing" {
+				t.Errorf("expected service to be '%s' but got '%s'", "billing", res["service"])
+			}
+			heartbeats++
+		case <-timeout:
+			t.Fatalf("expected at least 2 heartbeats but got %d", heartbeats)
+		}
+	}
+
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Drain a heartbeat possibly shipped while closing.
+	time.Sleep(30 * time.Millisecond)
+	for len(writes) > 0 {
+		<-writes
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(writes) != 0 {
+		t.Errorf("expected no heartbeat after Close but got %d", len(writes))
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	// os/signal starts a goroutine of its own, for good.
+	signalNotify = func(c chan<- os.Signal, sigs ...os.Signal) {}
+	signalStop = func(c chan<- os.Signal) {}
+	defer func() {
+		signalNotify = signal.Notify
+		signalStop = signal.Stop
+	}()
+	before := runtime.NumGoroutine()
+
+	hook, err := NewHook("tcp", ln.Addr().String(), "close_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Heartbeat = time.Millisecond
+	hook.HandleSignals()
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := hook.Close(); err != nil {
+			t.Errorf("expected Close %d to succeed but got '%v'", i+1, err)
+		}
+	}
+
+	// Goroutines which returned may take a moment to be accounted for.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected at most %d goroutines after Close but got %d", before, after)
+	}
+}
@@ -0,0 +1,64 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewHTTPHook creates a new hook which POSTs entries to the `http` input of a
+// Logstash instance at endpoint.
+func NewHTTPHook(endpoint, appName string) *Hook {
+	return &Hook{HTTPEndpoint: endpoint, appName: appName, alwaysSentFields: make(logrus.Fields)}
+}
+
+// httpWriter POSTs every write to the hook's HTTPEndpoint.
+type httpWriter struct {
+	h *Hook
+}
+
+func (w httpWriter) Write(b []byte) (int, error) {
+	var body io.Reader = bytes.NewReader(b)
+	if w.h.HTTPGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		body = &buf
+	}
+
+	req, err := http.NewRequest("POST", w.h.HTTPEndpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.h.HTTPGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if w.h.HTTPUsername != "" || w.h.HTTPPassword != "" {
+		req.SetBasicAuth(w.h.HTTPUsername, w.h.HTTPPassword)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("Logstash responded with %s", resp.Status)
+	}
+	return len(b), nil
+}
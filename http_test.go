@@ -0,0 +1,132 @@
+package logrus_logstash
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireHTTP(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		received := make(chan map[string]string, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				t.Errorf("expected method to be '%s' but got '%s'", "POST", r.Method)
+			}
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected Content-Type to be '%s' but got '%s'", "application/json", ct)
+			}
+			if user, pass, ok := r.BasicAuth(); !ok || user != "logstash" || pass != "secret" {
+				t.Errorf("expected basic auth 'logstash:secret' but got '%s:%s'", user, pass)
+			}
+			var body io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				body = zr
+			}
+			var res map[string]string
+			if err := json.NewDecoder(body).Decode(&res); err != nil {
+				t.Error(err)
+			}
+			received <- res
+		}))
+
+		hook := NewHTTPHook(server.URL, "http_test")
+		hook.HTTPUsername = "logstash"
+		hook.HTTPPassword = "secret"
+		hook.HTTPGzip = gzipped
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{"user": "mick"}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		res := <-received
+		server.Close()
+
+		expected := map[string]string{"message": "hello world!", "user": "mick", "type": "http_test"}
+		for k, v := range expected {
+			if res[k] != v {
+				t.Errorf("expected %s to be '%s' but got '%s'", k, v, res[k])
+			}
+		}
+	}
+}
+
+func TestFireHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL, "http_test")
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Error("expected Fire to fail when Logstash responds with an error")
+	}
+}
+
+func TestFireHTTPLargeEntry(t *testing.T) {
+	message := strings.Repeat("x", 200<<10)
+	posts := make(chan map[string]string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			t.Error(err)
+		}
+		posts <- res
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL, "http_test")
+	entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("expected the entry to be POSTed once but got '%d' requests", len(posts))
+	}
+	if res := <-posts; res["message"] != message {
+		t.Errorf("expected message to be '%d' bytes but got '%d'", len(message), len(res["message"]))
+	}
+}
+
+func TestFireHTTPBatch(t *testing.T) {
+	posts := make(chan []map[string]string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res []map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			t.Error(err)
+		}
+		posts <- res
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL, "http_test")
+	hook.BatchSize = 3
+	for _, message := range []string{"first", "second", "third"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(posts) != 1 {
+		t.Fatalf("expected the batch to be POSTed once but got '%d' requests", len(posts))
+	}
+	res := <-posts
+	if len(res) != 3 {
+		t.Fatalf("expected the batch to be an array of %d entries but got '%v'", 3, res)
+	}
+	for i, expected := range []string{"first", "second", "third"} {
+		if res[i]["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res[i]["message"])
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package logrus_logstash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hashContent sets the ContentHashField of the entry to the SHA-256 of the
+// entry formatted without it. Verifiers recompute it by formatting the
+// shipped fields but the hash the same way.
+func (h *Hook) hashContent(entry *logrus.Entry) error {
+	delete(entry.Data, h.ContentHashField)
+	data, err := h.format(entry)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(trimNewline(data))
+	entry.Data[h.ContentHashField] = hex.EncodeToString(sum[:])
+	return nil
+}
@@ -0,0 +1,46 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireContentHash(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "integrity_test",
+		alwaysSentFields: logrus.Fields{"env": "prod"},
+		ContentHashField: "@hash",
+	}
+	entry := &logrus.Entry{
+		Message: "transfer",
+		Data:    logrus.Fields{"amount": 100, "to": "bob", "@hash": "forged"},
+		Time:    time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		Level:   logrus.InfoLevel,
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.Unmarshal(conn.buff.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	shipped, _ := res["@hash"].(string)
+	delete(res, "@hash")
+	core, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(core)
+	if expected := hex.EncodeToString(sum[:]); shipped != expected {
+		t.Errorf("expected @hash to be '%s' but got '%s'", expected, shipped)
+	}
+}
@@ -0,0 +1,19 @@
+package logrus_logstash
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// internal reports whether the entry was logged by the hook's InternalLogger,
+// which the hook must not ship, lest its diagnostics loop through it.
+func (h *Hook) internal(entry *logrus.Entry) bool {
+	return h.InternalLogger != nil && entry.Logger == h.InternalLogger
+}
+
+// logInternal logs a diagnostic of the hook itself to InternalLogger, if set.
+func (h *Hook) logInternal(level logrus.Level, message string, fields logrus.Fields) {
+	if h.InternalLogger == nil {
+		return
+	}
+	h.InternalLogger.WithFields(fields).Log(level, message)
+}
@@ -0,0 +1,72 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestInternalLogger(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var internal bytes.Buffer
+	internalLogger := logrus.New()
+	internalLogger.Out = &internal
+	internalLogger.Formatter = &logrus.JSONFormatter{}
+	internalLogger.Level = logrus.DebugLevel
+
+	hook := NewLazyHook("tcp", ln.Addr().String(), "internal_test")
+	hook.InternalLogger = internalLogger
+	hook.Filter = func(entry *logrus.Entry) bool { return entry.Message != "filtered" }
+	defer hook.Close()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	// Entries of the internal logger don't loop through the hook.
+	internalLogger.Hooks.Add(hook)
+
+	logger.Info("hello world!")
+	logger.Info("filtered")
+
+	dec := json.NewDecoder(&internal)
+	var messages []string
+	for dec.More() {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["msg"] == "Reconnected" && res["address"] != ln.Addr().String() {
+			t.Errorf("expected address to be '%s' but got '%v'", ln.Addr().String(), res["address"])
+		}
+		if res["msg"] == "Dropped entry" && res["reason"] != "filtered" {
+			t.Errorf("expected reason to be '%s' but got '%v'", "filtered", res["reason"])
+		}
+		messages = append(messages, res["msg"].(string))
+	}
+	expected := []string{"Reconnected", "Dropped entry"}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected internal messages to be '%v' but got '%v'", expected, messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("expected internal messages to be '%v' but got '%v'", expected, messages)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// LazyValue is a field value computed only if the entry carrying it is
+// formatted, e.g. an expensive dump which filtered or sampled out entries
+// shouldn't pay for. Create it with Lazy.
+type LazyValue struct {
+	once sync.Once
+	f    func() interface{}
+	v    interface{}
+}
+
+// Lazy returns a field value computed by f the first time the entry carrying
+// it is formatted, and reused when it is formatted again. Unlike a bare
+// func, which logrus drops from the fields of its entries, it can be passed to
+// WithField.
+func Lazy(f func() interface{}) *LazyValue {
+	return &LazyValue{f: f}
+}
+
+// Value returns the value computed by f, calling it on first use.
+func (l *LazyValue) Value() interface{} {
+	l.once.Do(func() {
+		l.v = l.f()
+	})
+	return l.v
+}
+
+// MarshalJSON marshals the value, for formatters other than the
+// LogstashFormatter.
+func (l *LazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Value())
+}
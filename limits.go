@@ -0,0 +1,165 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// truncatedField marks entries which lost fields or part of their message to
+// the MaxFields, MaxDistinctKeys, LargeEntryThreshold or MaxPayloadBytes
+// limits.
+const truncatedField = "@truncated"
+
+// limitFields returns the entry with at most MaxFields fields, keeping the
+// first ones in key order. The entry is copied first so that other hooks and
+// the logger still see all the fields.
+func (h *Hook) limitFields(entry *logrus.Entry) *logrus.Entry {
+	if h.MaxFields <= 0 || len(entry.Data) <= h.MaxFields {
+		return entry
+	}
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	limited := copyEntry(entry)
+	for _, k := range keys[h.MaxFields:] {
+		delete(limited.Data, k)
+	}
+	limited.Data[truncatedField] = true
+	return limited
+}
+
+// limitDistinctKeys returns the entry without the fields whose keys would take
+// the number of distinct keys shipped by the hook beyond MaxDistinctKeys.
+// Fields whose keys shipped before are always kept.
+func (h *Hook) limitDistinctKeys(entry *logrus.Entry) *logrus.Entry {
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var dropped []string
+	h.mu.Lock()
+	if h.seenKeys == nil {
+		h.seenKeys = make(map[string]bool)
+	}
+	for _, k := range keys {
+		if h.seenKeys[k] {
+			continue
+		}
+		if len(h.seenKeys) < h.MaxDistinctKeys {
+			h.seenKeys[k] = true
+			continue
+		}
+		dropped = append(dropped, k)
+	}
+	h.mu.Unlock()
+
+	if len(dropped) == 0 {
+		return entry
+	}
+	if h.OnError != nil {
+		h.reportError(fmt.Errorf("Dropped fields %v beyond %d distinct keys", dropped, h.MaxDistinctKeys))
+	}
+	limited := copyEntry(entry)
+	for _, k := range dropped {
+		delete(limited.Data, k)
+	}
+	limited.Data[truncatedField] = true
+	return limited
+}
+
+// formatAllowlisted formats the entry with only the fields in
+// FieldAllowlistForLargeEntries, and its sequence number and content hash.
+func (h *Hook) formatAllowlisted(entry *logrus.Entry) ([]byte, error) {
+	limited := copyEntry(entry)
+	limited.Data = logrus.Fields{truncatedField: true}
+	for _, k := range append([]string{h.SequenceField}, h.FieldAllowlistForLargeEntries...) {
+		if v, ok := entry.Data[k]; ok && k != "" {
+			limited.Data[k] = v
+		}
+	}
+	if h.ContentHashField != "" {
+		if err := h.hashContent(limited); err != nil {
+			return nil, err
+		}
+	}
+	return h.format(limited)
+}
+
+// formatTruncated formats the entry without its fields but its sequence
+// number, and if that's still
+// more than MaxPayloadBytes, with its message cut short.
+func (h *Hook) formatTruncated(entry *logrus.Entry) ([]byte, error) {
+	truncated := copyEntry(entry)
+	truncated.Data = logrus.Fields{truncatedField: true}
+	if seq, ok := entry.Data[h.SequenceField]; ok && h.SequenceField != "" {
+		truncated.Data[h.SequenceField] = seq
+	}
+	dataBytes, err := h.format(truncated)
+	if err != nil || len(dataBytes) <= h.MaxPayloadBytes {
+		return dataBytes, err
+	}
+
+	excess := len(dataBytes) - h.MaxPayloadBytes
+	message := truncated.Message
+	if excess >= len(message) {
+		message = ""
+	} else {
+		message = message[:len(message)-excess]
+		// Don't leave half a character behind.
+		for len(message) > 0 && !utf8.ValidString(message) {
+			message = message[:len(message)-1]
+		}
+	}
+	truncated.Message = message
+	return h.format(truncated)
+}
+
+// stackTraceMarker replaces the frames trimmed from stack traces.
+const stackTraceMarker = "…"
+
+// limitStackTrace returns the entry with the stack trace in StackTraceField
+// trimmed to StackTraceMaxFrames frames.
+func (h *Hook) limitStackTrace(entry *logrus.Entry) *logrus.Entry {
+	key := h.StackTraceField
+	if key == "" {
+		key = "@stacktrace"
+	}
+	var stack string
+	switch v := entry.Data[key].(type) {
+	case string:
+		stack = v
+	case []byte:
+		stack = string(v)
+	default:
+		return entry
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stack, "\n"), "\n")
+	start := 0
+	// Go stack traces start with a goroutine header, which is no frame.
+	if strings.HasPrefix(lines[0], "goroutine ") {
+		start = 1
+	}
+	frames := 0
+	for i := start; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "\t") || strings.HasPrefix(lines[i], " ") {
+			continue
+		}
+		if frames == h.StackTraceMaxFrames {
+			limited := copyEntry(entry)
+			limited.Data[key] = strings.Join(append(lines[:i:i], stackTraceMarker), "\n")
+			return limited
+		}
+		frames++
+	}
+	return entry
+}
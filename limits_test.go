@@ -0,0 +1,239 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireMaxFields(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "max_fields_test",
+		alwaysSentFields: logrus.Fields{},
+		MaxFields:        2,
+	}
+	entry := &logrus.Entry{
+		Message: "hello world!",
+		Data:    logrus.Fields{"a": "1", "b": "2", "c": "3", "d": "4"},
+		Level:   logrus.InfoLevel,
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res[truncatedField] != true {
+		t.Errorf("expected %s to be true but got '%v'", truncatedField, res[truncatedField])
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, ok := res[key]; !ok {
+			t.Errorf("expected %s to be shipped", key)
+		}
+	}
+	for _, key := range []string{"c", "d"} {
+		if _, ok := res[key]; ok {
+			t.Errorf("expected %s to be dropped", key)
+		}
+	}
+	for _, key := range []string{"@timestamp", "@version", "message", "level", "type"} {
+		if _, ok := res[key]; !ok {
+			t.Errorf("expected %s to be shipped", key)
+		}
+	}
+	if len(entry.Data) != 4 {
+		t.Error("expected the entry to keep all its fields")
+	}
+}
+
+func TestFireMaxDistinctKeys(t *testing.T) {
+	var reported []error
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "distinct_keys_test",
+		alwaysSentFields: logrus.Fields{},
+		MaxDistinctKeys:  3,
+		OnError: func(err error, suppressed int) {
+			reported = append(reported, err)
+		},
+	}
+	tt := []struct {
+		data      logrus.Fields
+		shipped   []string
+		dropped   []string
+		truncated bool
+	}{
+		{logrus.Fields{"a": "1", "b": "2"}, []string{"a", "b"}, nil, false},
+		{logrus.Fields{"a": "1", "c": "3", "d": "4", "e": "5"}, []string{"a", "c"}, []string{"d", "e"}, true},
+		{logrus.Fields{"b": "2", "d": "4"}, []string{"b"}, []string{"d"}, true},
+		{logrus.Fields{"a": "1", "b": "2", "c": "3"}, []string{"a", "b", "c"}, nil, false},
+	}
+
+	for _, te := range tt {
+		entry := &logrus.Entry{Message: "hello world!", Data: te.data, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range te.shipped {
+			if _, ok := res[key]; !ok {
+				t.Errorf("expected %s to be shipped", key)
+			}
+		}
+		for _, key := range te.dropped {
+			if _, ok := res[key]; ok {
+				t.Errorf("expected %s to be dropped", key)
+			}
+		}
+		if _, truncated := res[truncatedField]; truncated != te.truncated {
+			t.Errorf("expected %s to be set: %v", truncatedField, te.truncated)
+		}
+	}
+	if len(reported) != 2 {
+		t.Errorf("expected 2 reported errors but got '%v'", reported)
+	}
+}
+
+func TestFireMaxPayloadBytes(t *testing.T) {
+	tt := []struct {
+		message string
+		fields  logrus.Fields
+	}{
+		{"hello world!", logrus.Fields{"big": strings.Repeat("x", 1000)}},
+		{strings.Repeat("y", 1000), logrus.Fields{}},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "max_payload_test",
+			alwaysSentFields: logrus.Fields{},
+			MaxPayloadBytes:  200,
+		}
+		entry := &logrus.Entry{Message: te.message, Data: te.fields, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if conn.buff.Len() > 200 {
+			t.Errorf("expected payload to be at most %d bytes but got %d", 200, conn.buff.Len())
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[truncatedField] != true {
+			t.Errorf("expected %s to be true but got '%v'", truncatedField, res[truncatedField])
+		}
+		if _, ok := res["big"]; ok {
+			t.Error("expected big to be dropped")
+		}
+		if res["level"] != "info" {
+			t.Errorf("expected level to be '%s' but got '%v'", "info", res["level"])
+		}
+	}
+}
+
+func TestFireLargeEntryThreshold(t *testing.T) {
+	tt := []struct {
+		fields   logrus.Fields
+		expected logrus.Fields
+	}{
+		{
+			logrus.Fields{"user": "alice", "request": "abc", "debug": strings.Repeat("x", 1000)},
+			logrus.Fields{"user": "alice", "request": "abc", truncatedField: true},
+		},
+		{
+			logrus.Fields{"user": "alice", "request": "abc", "debug": "x"},
+			logrus.Fields{"user": "alice", "request": "abc", "debug": "x"},
+		},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:                          conn,
+			appName:                       "large_entry_test",
+			alwaysSentFields:              logrus.Fields{},
+			LargeEntryThreshold:           500,
+			FieldAllowlistForLargeEntries: []string{"user", "request", "missing"},
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: te.fields, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"@version", "@timestamp", "message", "level", "type"} {
+			delete(res, k)
+		}
+		if !reflect.DeepEqual(map[string]interface{}(te.expected), res) {
+			t.Errorf("expected fields to be '%v' but got '%v'", te.expected, res)
+		}
+	}
+}
+
+func TestFireStackTraceMaxFrames(t *testing.T) {
+	lines := []string{"goroutine 1 [running]:"}
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("main.f%d()", i), fmt.Sprintf("\t/app/main.go:%d +0x1d", i))
+	}
+	stack := strings.Join(lines, "\n") + "\n"
+
+	tt := []struct {
+		field    string
+		frames   int
+		expected string
+	}{
+		{"", 3, strings.Join(append(lines[:7:7], "…"), "\n")},
+		{"stack", 1, strings.Join(append(lines[:3:3], "…"), "\n")},
+		{"", 50, stack},
+	}
+
+	for _, te := range tt {
+		key := te.field
+		if key == "" {
+			key = "@stacktrace"
+		}
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:                conn,
+			appName:             "stack_test",
+			alwaysSentFields:    logrus.Fields{},
+			StackTraceMaxFrames: te.frames,
+			StackTraceField:     te.field,
+		}
+		entry := &logrus.Entry{Message: "panic", Data: logrus.Fields{key: stack}, Level: logrus.ErrorLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[key] != te.expected {
+			t.Errorf("expected %s to be '%s' but got '%v'", key, te.expected, res[key])
+		}
+		if entry.Data[key] != stack {
+			t.Errorf("expected the logger's %s to be left whole", key)
+		}
+	}
+}
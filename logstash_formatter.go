@@ -3,6 +3,9 @@ package logrus_logstash
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -16,6 +19,111 @@ type LogstashFormatter struct {
 
 	// TimestampFormat sets the format used for timestamps.
 	TimestampFormat string
+
+	// DisableVersion omits the `@version` field, which is noise for sinks
+	// other than Logstash.
+	DisableVersion bool
+
+	// Tags, if not empty, are emitted as a JSON array under TagsKey, which
+	// Logstash pipelines commonly route on.
+	Tags []string
+	// TagsKey sets the key used for Tags. Defaults to "tags".
+	TagsKey string
+
+	// NumericLevelField, if not empty, is the key under which the syslog
+	// severity of the entry level is emitted alongside `level`, e.g. for
+	// dashboards sorting on severity.
+	NumericLevelField string
+
+	// NestFieldsUnder, if not empty, nests the entry's fields in an object
+	// under that key instead of placing them alongside the message.
+	NestFieldsUnder string
+
+	// CollapseNewlines, if not empty, replaces the newlines in the message,
+	// e.g. of a stack trace, so that it fits on a single line for per-line
+	// Logstash codecs.
+	CollapseNewlines string
+
+	// DurationFormat sets how time.Duration field values are formatted.
+	// Defaults to DurationNanos.
+	DurationFormat DurationFormat
+	// TimeFormat, if not empty, is the layout used to format time.Time field
+	// values, e.g. the same as TimestampFormat.
+	TimeFormat string
+
+	// ReservedKeyPolicy sets how fields colliding with the keys set by the
+	// formatter itself, such as `@timestamp` or `message`, are handled.
+	// Defaults to ReservedKeyDefault.
+	ReservedKeyPolicy ReservedKeyPolicy
+
+	// NonFiniteFloatPolicy sets how NaN and infinite float field values,
+	// which JSON cannot represent, are formatted. Defaults to
+	// NonFiniteFloatNull.
+	NonFiniteFloatPolicy NonFiniteFloatPolicy
+
+	// SkipUnsupportedFields drops the fields JSON cannot represent, such as
+	// funcs, channels and complex numbers, instead of failing the entry.
+	SkipUnsupportedFields bool
+
+	// FieldSerializers convert field values of the given types to the values
+	// marshaled instead, e.g. *big.Int values to decimal strings. They take
+	// precedence over the formatter's own conversions.
+	FieldSerializers map[reflect.Type]func(interface{}) interface{}
+}
+
+// DurationFormat sets how a LogstashFormatter formats time.Duration values.
+type DurationFormat string
+
+const (
+	// DurationNanos formats durations as integer nanoseconds.
+	DurationNanos DurationFormat = "nanos"
+	// DurationMillis formats durations as integer milliseconds.
+	DurationMillis DurationFormat = "millis"
+	// DurationString formats durations as strings such as "1m30s".
+	DurationString DurationFormat = "string"
+)
+
+// ReservedKeyPolicy sets how a LogstashFormatter handles fields colliding with
+// its reserved keys.
+type ReservedKeyPolicy int
+
+const (
+	// ReservedKeyDefault ships fields colliding with `message`, `level` or
+	// `type` under `fields.<key>`, and drops other colliding fields in favor
+	// of the formatter's values.
+	ReservedKeyDefault ReservedKeyPolicy = iota
+	// ReservedKeyRename ships colliding fields under `fields.<key>`.
+	ReservedKeyRename
+	// ReservedKeyOverride drops colliding fields in favor of the formatter's
+	// values.
+	ReservedKeyOverride
+	// ReservedKeyError fails formatting entries with colliding fields.
+	ReservedKeyError
+)
+
+// NonFiniteFloatPolicy sets how a LogstashFormatter formats NaN and infinite
+// float values.
+type NonFiniteFloatPolicy int
+
+const (
+	// NonFiniteFloatNull formats non-finite floats as null.
+	NonFiniteFloatNull NonFiniteFloatPolicy = iota
+	// NonFiniteFloatString formats non-finite floats as the strings "NaN",
+	// "+Inf" and "-Inf".
+	NonFiniteFloatString
+)
+
+// reservedField is a field set by the formatter itself.
+type reservedField struct {
+	key   string
+	value interface{}
+}
+
+// NewLegacyCompatFormatter returns a formatter whose output is identical to
+// that of LogstashFormatter{Type: appName}, which is what a Hook created with
+// appName ships by default.
+func NewLegacyCompatFormatter(appName string) logrus.Formatter {
+	return &LogstashFormatter{Type: appName}
 }
 
 func (f *LogstashFormatter) Format(entry *logrus.Entry) ([]byte, error) {
@@ -30,17 +138,13 @@ func (f *LogstashFormatter) FormatWithPrefix(entry *logrus.Entry, prefix string)
 			k = strings.TrimPrefix(k, prefix)
 		}
 
-		switch v := v.(type) {
-		case error:
-			// Otherwise errors are ignored by `encoding/json`
-			// https://github.com/Sirupsen/logrus/issues/377
-			fields[k] = v.Error()
-		default:
-			fields[k] = v
-		}
+		fields[k] = f.formatValue(v)
 	}
 
-	fields["@version"] = "1"
+	data := fields
+	if f.NestFieldsUnder != "" {
+		data = logrus.Fields{f.NestFieldsUnder: fields}
+	}
 
 	timeStampFormat := f.TimestampFormat
 
@@ -48,34 +152,140 @@ func (f *LogstashFormatter) FormatWithPrefix(entry *logrus.Entry, prefix string)
 		timeStampFormat = time.RFC3339
 	}
 
-	fields["@timestamp"] = entry.Time.Format(timeStampFormat)
-
-	// set message field
-	v, ok := entry.Data["message"]
-	if ok {
-		fields["fields.message"] = v
+	message := entry.Message
+	if f.CollapseNewlines != "" {
+		message = strings.NewReplacer("\r\n", f.CollapseNewlines, "\n", f.CollapseNewlines).Replace(message)
 	}
-	fields["message"] = entry.Message
 
-	// set level field
-	v, ok = entry.Data["level"]
-	if ok {
-		fields["fields.level"] = v
+	var reserved []reservedField
+	if !f.DisableVersion {
+		reserved = append(reserved, reservedField{"@version", "1"})
+	}
+	reserved = append(reserved, []reservedField{
+		{"@timestamp", entry.Time.Format(timeStampFormat)},
+		{"message", message},
+		{"level", entry.Level.String()},
+	}...)
+	if f.NumericLevelField != "" {
+		reserved = append(reserved, reservedField{f.NumericLevelField, syslogSeverity(entry.Level)})
 	}
-	fields["level"] = entry.Level.String()
-
-	// set type field
 	if f.Type != "" {
-		v, ok = entry.Data["type"]
-		if ok {
-			fields["fields.type"] = v
+		reserved = append(reserved, reservedField{"type", f.Type})
+	}
+	if len(f.Tags) > 0 {
+		tagsKey := f.TagsKey
+		if tagsKey == "" {
+			tagsKey = "tags"
 		}
-		fields["type"] = f.Type
+		reserved = append(reserved, reservedField{tagsKey, f.Tags})
+	}
+
+	for _, r := range reserved {
+		if v, ok := fields[r.key]; ok && f.NestFieldsUnder == "" {
+			switch f.ReservedKeyPolicy {
+			case ReservedKeyDefault:
+				if r.key == "message" || r.key == "level" || r.key == "type" {
+					data["fields."+r.key] = v
+				}
+			case ReservedKeyRename:
+				data["fields."+r.key] = v
+			case ReservedKeyError:
+				return nil, fmt.Errorf("Field %q collides with a reserved key", r.key)
+			}
+		}
+		data[r.key] = r.value
+	}
+
+	// Entries with string values only, the most common ones, skip the
+	// reflection of encoding/json.
+	if serialized, ok := appendFlatJSON(nil, data); ok {
+		return serialized, nil
 	}
 
-	serialized, err := json.Marshal(fields)
+	serialized, err := json.Marshal(data)
+	if err != nil && f.SkipUnsupportedFields {
+		dropUnsupported(fields)
+		dropUnsupported(data)
+		serialized, err = json.Marshal(data)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal fields to JSON, %v", err)
 	}
 	return append(serialized, '\n'), nil
 }
+
+// dropUnsupported deletes the fields failing to marshal to JSON.
+func dropUnsupported(fields logrus.Fields) {
+	for k, v := range fields {
+		if _, err := json.Marshal(v); err != nil {
+			delete(fields, k)
+		}
+	}
+}
+
+// formatValue converts a field value to the value marshaled to JSON.
+func (f *LogstashFormatter) formatValue(v interface{}) interface{} {
+	if serialize, ok := f.FieldSerializers[reflect.TypeOf(v)]; ok && v != nil {
+		return serialize(v)
+	}
+	switch v := v.(type) {
+	case *LazyValue:
+		// Lazy fields are only evaluated for the entries actually formatted
+		return f.formatValue(v.Value())
+	case error:
+		// Otherwise errors are ignored by `encoding/json`
+		// https://github.com/Sirupsen/logrus/issues/377
+		return v.Error()
+	case time.Duration:
+		return f.formatDuration(v)
+	case time.Time:
+		if f.TimeFormat != "" {
+			return v.Format(f.TimeFormat)
+		}
+		return v
+	case json.Marshaler:
+		return v
+	case fmt.Stringer:
+		if isNilPointer(v) {
+			// Marshaled as null, as String would dereference it
+			return v
+		}
+		// Otherwise only the exported fields of the value are marshaled
+		return v.String()
+	case float64:
+		return f.formatFloat(v)
+	case float32:
+		return f.formatFloat(float64(v))
+	default:
+		return v
+	}
+}
+
+// isNilPointer reports whether v is a typed nil pointer.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// formatFloat formats non-finite floats, which would otherwise fail the whole
+// entry to marshal, per the NonFiniteFloatPolicy.
+func (f *LogstashFormatter) formatFloat(v float64) interface{} {
+	if !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return v
+	}
+	if f.NonFiniteFloatPolicy == NonFiniteFloatString {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return nil
+}
+
+func (f *LogstashFormatter) formatDuration(d time.Duration) interface{} {
+	switch f.DurationFormat {
+	case DurationMillis:
+		return int64(d / time.Millisecond)
+	case DurationString:
+		return d.String()
+	default:
+		return int64(d)
+	}
+}
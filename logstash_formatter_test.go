@@ -2,10 +2,15 @@ package logrus_logstash
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -70,3 +75,447 @@ func TestLogstashFormatter(t *testing.T) {
 		t.Errorf("expected bool to be '%v' but got '%v'", true, data["bool"])
 	}
 }
+
+func TestNewLegacyCompatFormatter(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &logrus.Entry{
+		Message: "hello world!",
+		Data:    logrus.Fields{"one": 1, "message": "def"},
+		Time:    time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		Level:   logrus.WarnLevel,
+	}
+
+	expected, err := NewLegacyCompatFormatter("legacy").Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, conn.buff.Bytes()) {
+		t.Errorf("expected output to be '%s' but got '%s'", expected, conn.buff.Bytes())
+	}
+}
+
+func TestLogstashFormatterCollapseNewlines(t *testing.T) {
+	lf := LogstashFormatter{CollapseNewlines: " | "}
+	entry := &logrus.Entry{
+		Message: "panic: boom\ngoroutine 1 [running]:\nmain.main()",
+		Data:    logrus.Fields{},
+		Level:   logrus.ErrorLevel,
+	}
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(b, []byte("\n")) != 1 {
+		t.Errorf("expected a single line but got '%s'", b)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	expected := "panic: boom | goroutine 1 [running]: | main.main()"
+	if data["message"] != expected {
+		t.Errorf("expected message to be '%s' but got '%v'", expected, data["message"])
+	}
+}
+
+func TestLogstashFormatterNestFieldsUnder(t *testing.T) {
+	lf := LogstashFormatter{Type: "abc", NestFieldsUnder: "fields"}
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data:    logrus.Fields{"user": "mick", "message": "def", "one": 1},
+		Level:   logrus.InfoLevel,
+	}
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"user": "mick", "message": "def", "one": float64(1)}
+	if !reflect.DeepEqual(expected, data["fields"]) {
+		t.Errorf("expected fields to be '%v' but got '%v'", expected, data["fields"])
+	}
+	for _, key := range []string{"user", "one", "fields.message"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("expected %s not to be at the top level", key)
+		}
+	}
+	tt := []struct {
+		expected string
+		key      string
+	}{
+		{"1", "@version"},
+		{"abc", "type"},
+		{"msg", "message"},
+		{"info", "level"},
+	}
+	for _, te := range tt {
+		if te.expected != data[te.key] {
+			t.Errorf("expected data[%s] to be '%s' but got '%v'", te.key, te.expected, data[te.key])
+		}
+	}
+}
+
+func TestLogstashFormatterReservedKeyPolicy(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data:    logrus.Fields{"@timestamp": "yesterday", "message": "def", "user": "mick"},
+		Time:    time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		Level:   logrus.InfoLevel,
+	}
+	tt := []struct {
+		policy   ReservedKeyPolicy
+		expected map[string]interface{}
+	}{
+		{ReservedKeyDefault, map[string]interface{}{
+			"@timestamp":     "2017-05-01T10:00:00Z",
+			"@version":       "1",
+			"message":        "msg",
+			"level":          "info",
+			"user":           "mick",
+			"fields.message": "def",
+		}},
+		{ReservedKeyRename, map[string]interface{}{
+			"@timestamp":        "2017-05-01T10:00:00Z",
+			"@version":          "1",
+			"message":           "msg",
+			"level":             "info",
+			"user":              "mick",
+			"fields.@timestamp": "yesterday",
+			"fields.message":    "def",
+		}},
+		{ReservedKeyOverride, map[string]interface{}{
+			"@timestamp": "2017-05-01T10:00:00Z",
+			"@version":   "1",
+			"message":    "msg",
+			"level":      "info",
+			"user":       "mick",
+		}},
+		{ReservedKeyError, nil},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{ReservedKeyPolicy: te.policy}
+		b, err := lf.Format(entry)
+		if te.expected == nil {
+			if err == nil {
+				t.Errorf("expected policy %d to fail on colliding fields", te.policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(te.expected, data) {
+			t.Errorf("expected data to be '%v' but got '%v'", te.expected, data)
+		}
+	}
+}
+
+func TestLogstashFormatterDurationAndTimeFormat(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data: logrus.Fields{
+			"elapsed": 1500 * time.Millisecond,
+			"started": time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		},
+		Level: logrus.InfoLevel,
+	}
+	tt := []struct {
+		durationFormat DurationFormat
+		timeFormat     string
+		elapsed        interface{}
+		started        interface{}
+	}{
+		{"", "", json.Number("1500000000"), "2017-05-01T10:00:00Z"},
+		{DurationNanos, time.RFC1123, json.Number("1500000000"), "Mon, 01 May 2017 10:00:00 UTC"},
+		{DurationMillis, time.Kitchen, json.Number("1500"), "10:00AM"},
+		{DurationString, "2006-01-02", "1.5s", "2017-05-01"},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{DurationFormat: te.durationFormat, TimeFormat: te.timeFormat}
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		if data["elapsed"] != te.elapsed {
+			t.Errorf("expected elapsed to be '%v' but got '%v'", te.elapsed, data["elapsed"])
+		}
+		if data["started"] != te.started {
+			t.Errorf("expected started to be '%v' but got '%v'", te.started, data["started"])
+		}
+	}
+}
+
+func TestLogstashFormatterDisableVersion(t *testing.T) {
+	entry := &logrus.Entry{Message: "msg", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	for _, disabled := range []bool{false, true} {
+		lf := LogstashFormatter{DisableVersion: disabled}
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := data["@version"]; ok == disabled {
+			t.Errorf("expected @version to be present: %v", !disabled)
+		}
+	}
+}
+
+func TestLogstashFormatterNumericLevelField(t *testing.T) {
+	tt := []struct {
+		level    logrus.Level
+		severity json.Number
+	}{
+		{logrus.PanicLevel, "0"},
+		{logrus.FatalLevel, "2"},
+		{logrus.ErrorLevel, "3"},
+		{logrus.WarnLevel, "4"},
+		{logrus.InfoLevel, "6"},
+		{logrus.DebugLevel, "7"},
+	}
+
+	lf := LogstashFormatter{NumericLevelField: "severity"}
+	for _, te := range tt {
+		entry := &logrus.Entry{Message: "msg", Data: logrus.Fields{}, Level: te.level}
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		if data["level"] != te.level.String() {
+			t.Errorf("expected level to be '%s' but got '%v'", te.level, data["level"])
+		}
+		if data["severity"] != te.severity {
+			t.Errorf("expected severity of %s to be '%s' but got '%v'", te.level, te.severity, data["severity"])
+		}
+	}
+}
+
+type stringerMock struct {
+	major, minor int
+}
+
+func (s stringerMock) String() string {
+	return fmt.Sprintf("v%d.%d", s.major, s.minor)
+}
+
+func TestLogstashFormatterStringer(t *testing.T) {
+	lf := LogstashFormatter{}
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data: logrus.Fields{
+			"version": stringerMock{1, 2},
+			"pointer": &stringerMock{3, 4},
+			"nil":     (*stringerMock)(nil),
+			"url":     (*url.URL)(nil),
+		},
+		Level: logrus.InfoLevel,
+	}
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["version"] != "v1.2" {
+		t.Errorf("expected version to be '%s' but got '%v'", "v1.2", data["version"])
+	}
+	if data["pointer"] != "v3.4" {
+		t.Errorf("expected pointer to be '%s' but got '%v'", "v3.4", data["pointer"])
+	}
+	for _, key := range []string{"nil", "url"} {
+		if value, ok := data[key]; !ok || value != nil {
+			t.Errorf("expected %s to be null but got '%v'", key, value)
+		}
+	}
+}
+
+func TestLogstashFormatterNonFiniteFloatPolicy(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data: logrus.Fields{
+			"ratio": math.Inf(1),
+			"low":   float32(math.Inf(-1)),
+			"mean":  math.NaN(),
+			"pi":    3.14,
+		},
+		Level: logrus.InfoLevel,
+	}
+	tt := []struct {
+		policy   NonFiniteFloatPolicy
+		expected map[string]interface{}
+	}{
+		{NonFiniteFloatNull, map[string]interface{}{"ratio": nil, "low": nil, "mean": nil, "pi": 3.14}},
+		{NonFiniteFloatString, map[string]interface{}{"ratio": "+Inf", "low": "-Inf", "mean": "NaN", "pi": 3.14}},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{NonFiniteFloatPolicy: te.policy}
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		for key, expected := range te.expected {
+			if v, ok := data[key]; !ok || v != expected {
+				t.Errorf("expected %s to be '%v' with policy %d but got '%v'", key, expected, te.policy, v)
+			}
+		}
+	}
+}
+
+func TestLogstashFormatterSkipUnsupportedFields(t *testing.T) {
+	for _, nest := range []string{"", "fields"} {
+		entry := &logrus.Entry{
+			Message: "msg",
+			Data: logrus.Fields{
+				"callback": func() {},
+				"done":     make(chan struct{}),
+				"z":        complex(1, 2),
+				"user":     "alice",
+			},
+			Level: logrus.InfoLevel,
+		}
+
+		lf := LogstashFormatter{NestFieldsUnder: nest}
+		if _, err := lf.Format(entry); err == nil {
+			t.Error("expected unsupported fields to fail formatting by default")
+		}
+
+		lf.SkipUnsupportedFields = true
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		fields := data
+		if nest != "" {
+			fields, _ = data[nest].(map[string]interface{})
+		}
+		if fields["user"] != "alice" {
+			t.Errorf("expected user to be '%s' but got '%v'", "alice", fields["user"])
+		}
+		for _, key := range []string{"callback", "done", "z"} {
+			if _, ok := fields[key]; ok {
+				t.Errorf("expected %s to be dropped", key)
+			}
+		}
+		if data["message"] != "msg" {
+			t.Errorf("expected message to be '%s' but got '%v'", "msg", data["message"])
+		}
+	}
+}
+
+func TestLogstashFormatterSliceOfMaps(t *testing.T) {
+	events := []map[string]interface{}{
+		{"name": "cache.miss", "key": "user:1"},
+		{"name": "db.query", "rows": 3},
+	}
+	tt := []interface{}{
+		events,
+		[]logrus.Fields{events[0], events[1]},
+		[]interface{}{events[0], events[1]},
+	}
+
+	for _, value := range tt {
+		entry := &logrus.Entry{Message: "msg", Data: logrus.Fields{"span.events": value}, Level: logrus.InfoLevel}
+		lf := LogstashFormatter{}
+		b, err := lf.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{
+			map[string]interface{}{"name": "cache.miss", "key": "user:1"},
+			map[string]interface{}{"name": "db.query", "rows": float64(3)},
+		}
+		if !reflect.DeepEqual(expected, data["span.events"]) {
+			t.Errorf("expected span.events to be '%v' but got '%v'", expected, data["span.events"])
+		}
+	}
+}
+
+func TestLogstashFormatterFieldSerializers(t *testing.T) {
+	balance, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	entry := &logrus.Entry{
+		Message: "msg",
+		Data: logrus.Fields{
+			"payload": []byte("hello"),
+			"balance": balance,
+			"lazy":    Lazy(func() interface{} { return []byte("world") }),
+			"count":   3,
+		},
+		Level: logrus.InfoLevel,
+	}
+	lf := LogstashFormatter{FieldSerializers: map[reflect.Type]func(interface{}) interface{}{
+		reflect.TypeOf([]byte(nil)): func(v interface{}) interface{} {
+			return base64.RawURLEncoding.EncodeToString(v.([]byte))
+		},
+		reflect.TypeOf(balance): func(v interface{}) interface{} {
+			return v.(*big.Int).String()
+		},
+	}}
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"payload": "aGVsbG8",
+		"balance": "123456789012345678901234567890",
+		"lazy":    "d29ybGQ",
+		"count":   float64(3),
+	}
+	for key, value := range expected {
+		if data[key] != value {
+			t.Errorf("expected %s to be '%v' but got '%v'", key, value, data[key])
+		}
+	}
+}
@@ -2,10 +2,14 @@ package logrus_logstash
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -155,6 +159,72 @@ func TestSettingAttributes(t *testing.T) {
 	}
 }
 
+func TestFireContextFieldsShipOnce(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "context_test", logrus.Fields{"team": "payments", "region": "eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &logrus.Entry{
+		Message: "hello world!",
+		Data:    logrus.Fields{"team": "payments", "region": "us"},
+		Level:   logrus.InfoLevel,
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{`"team":`, `"region":`} {
+		if n := bytes.Count(conn.buff.Bytes(), []byte(key)); n != 1 {
+			t.Errorf("expected %s to be shipped once but got %d", key, n)
+		}
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["team"] != "payments" {
+		t.Errorf("expected team to be '%s' but got '%s'", "payments", res["team"])
+	}
+	if res["region"] != "us" {
+		t.Errorf("expected region to be '%s' but got '%s'", "us", res["region"])
+	}
+}
+
+func TestSetContextField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "context_test", logrus.Fields{"color": "blue", "region": "eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := hook.ContextFields()
+	expected := logrus.Fields{"color": "blue", "region": "eu"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected context fields to be '%v' but got '%v'", expected, fields)
+	}
+	fields["region"] = "us"
+	if hook.ContextFields()["region"] != "eu" {
+		t.Error("expected ContextFields to return a copy")
+	}
+
+	hook.SetContextField("color", "green")
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["color"] != "green" {
+		t.Errorf("expected color to be '%s' but got '%v'", "green", res["color"])
+	}
+	if res["region"] != "eu" {
+		t.Errorf("expected region to be '%s' but got '%v'", "eu", res["region"])
+	}
+}
+
 func TestFilterHookOnly(t *testing.T) {
 	tt := []struct {
 		entry    *logrus.Entry
@@ -256,6 +326,38 @@ func TestFire(t *testing.T) {
 	}
 }
 
+func TestSetFormatter(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "formatter_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fire := func() map[string]interface{} {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	hook.SetFormatter(&LogstashFormatter{Tags: []string{"a"}})
+	if res := fire(); !reflect.DeepEqual(res["tags"], []interface{}{"a"}) {
+		t.Errorf("expected tags to be '%v' but got '%v'", []string{"a"}, res["tags"])
+	}
+	hook.SetFormatter(&LogstashFormatter{Tags: []string{"b"}, DisableVersion: true})
+	res := fire()
+	if !reflect.DeepEqual(res["tags"], []interface{}{"b"}) {
+		t.Errorf("expected tags to be '%v' but got '%v'", []string{"b"}, res["tags"])
+	}
+	if _, ok := res["@version"]; ok {
+		t.Error("expected @version to be omitted by the new formatter")
+	}
+}
+
 func TestFireFilterHook(t *testing.T) {
 	hook := &Hook{
 		appName:          "fire_hook_test",
@@ -297,3 +399,1021 @@ func TestLevels(t *testing.T) {
 	}
 
 }
+
+func TestFireSequenceField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "sequence_test",
+		alwaysSentFields: logrus.Fields{},
+		SequenceField:    "seq",
+	}
+	for i := 0; i < 3; i++ {
+		entry := &logrus.Entry{
+			Message: "hello world!",
+			Data:    logrus.Fields{},
+			Level:   logrus.InfoLevel,
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for expected := uint64(1); expected <= 3; expected++ {
+		var res struct {
+			Seq uint64 `json:"seq"`
+		}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Seq != expected {
+			t.Errorf("expected seq to be '%d' but got '%d'", expected, res.Seq)
+		}
+	}
+}
+
+func TestFireLevelConns(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	errBuff := bytes.NewBufferString("")
+	infoBuff := bytes.NewBufferString("")
+	hook := &Hook{
+		conn:             conn,
+		appName:          "level_conns_test",
+		alwaysSentFields: logrus.Fields{},
+		LevelConns: map[logrus.Level]io.Writer{
+			logrus.ErrorLevel: errBuff,
+			logrus.InfoLevel:  infoBuff,
+		},
+	}
+	for _, level := range []logrus.Level{logrus.ErrorLevel, logrus.InfoLevel, logrus.DebugLevel} {
+		entry := &logrus.Entry{
+			Message: level.String(),
+			Data:    logrus.Fields{},
+			Level:   level,
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	tt := []struct {
+		buff     *bytes.Buffer
+		expected string
+	}{
+		{errBuff, "error"},
+		{infoBuff, "info"},
+		{conn.buff, "debug"},
+	}
+	for _, te := range tt {
+		var res map[string]string
+		dec := json.NewDecoder(te.buff)
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != te.expected {
+			t.Errorf("expected message to be '%s' but got '%s'", te.expected, res["message"])
+		}
+		if dec.More() {
+			t.Errorf("expected a single '%s' entry in the buffer", te.expected)
+		}
+	}
+}
+
+func TestNewLazyHook(t *testing.T) {
+	// Grab a free port and release it so nothing listens there.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	hook := NewLazyHook("tcp", address, "lazy")
+	if hook == nil {
+		t.Fatal("expected hook to be not nil")
+	}
+	if hook.conn != nil {
+		t.Error("expected conn to be nil before the first Fire")
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Error("expected Fire to fail dialing an unreachable address")
+	}
+
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	hook = NewLazyHook("tcp", ln.Addr().String(), "lazy")
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if hook.conn == nil {
+		t.Fatal("expected conn to be dialed by Fire")
+	}
+	defer hook.Close()
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	var res map[string]string
+	if err := json.NewDecoder(server).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello world!" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello world!", res["message"])
+	}
+}
+
+// chunkWriter records the size of the largest write to its connection.
+type chunkWriter struct {
+	net.Conn
+	largest int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+	return w.Conn.Write(p)
+}
+
+func TestFireLargeEntry(t *testing.T) {
+	message := strings.Repeat("x", 5<<20)
+	entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan map[string]string, 2)
+	go func() {
+		for {
+			server, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer server.Close()
+				var res map[string]string
+				json.NewDecoder(server).Decode(&res)
+				received <- res
+			}()
+		}
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	w := &chunkWriter{Conn: client}
+	hook := &Hook{conn: w, appName: "large_test", alwaysSentFields: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if w.largest > writeChunkSize {
+		t.Errorf("expected writes to be at most '%d' bytes but got '%d'", writeChunkSize, w.largest)
+	}
+	res := <-received
+	if res["message"] != message {
+		t.Errorf("expected message to be '%d' bytes but got '%d'", len(message), len(res["message"]))
+	}
+
+	hook, err = NewHook("tcp", ln.Addr().String(), "large_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	res = <-received
+	if res["message"] != message {
+		t.Errorf("expected message to be '%d' bytes but got '%d'", len(message), len(res["message"]))
+	}
+}
+
+func TestFireShipsLoggerFields(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "logger_fields_test",
+		alwaysSentFields: logrus.Fields{"service": "hook", "region": "eu"},
+	}
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	base := logger.WithFields(logrus.Fields{"service": "api", "version": "1.2"})
+	base.WithField("request", "abc").Info("hello world!")
+
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"service": "api",
+		"version": "1.2",
+		"request": "abc",
+		"region":  "eu",
+		"message": "hello world!",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%s' but got '%s'", k, v, res[k])
+		}
+	}
+}
+
+func TestFireTags(t *testing.T) {
+	tt := []struct {
+		tagsKey  string
+		expected string
+	}{
+		{"", "tags"},
+		{"@tags", "@tags"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "tags_test",
+			alwaysSentFields: logrus.Fields{},
+			Formatter:        &LogstashFormatter{Tags: []string{"billing", "critical"}, TagsKey: te.tagsKey},
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{"billing", "critical"}
+		if !reflect.DeepEqual(expected, res[te.expected]) {
+			t.Errorf("expected %s to be '%v' but got '%v'", te.expected, expected, res[te.expected])
+		}
+		if res["type"] != "tags_test" {
+			t.Errorf("expected type to be '%s' but got '%v'", "tags_test", res["type"])
+		}
+	}
+}
+
+func TestFireLevelFields(t *testing.T) {
+	tt := []struct {
+		level   logrus.Level
+		shipped bool
+	}{
+		{logrus.DebugLevel, true},
+		{logrus.InfoLevel, false},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "level_fields_test",
+			alwaysSentFields: logrus.Fields{},
+			LevelFields:      map[logrus.Level][]string{logrus.DebugLevel: {"request.body"}},
+		}
+		entry := &logrus.Entry{
+			Message: "hello world!",
+			Data:    logrus.Fields{"request.body": "{}", "request.path": "/"},
+			Level:   te.level,
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := res["request.body"]; ok != te.shipped {
+			t.Errorf("expected request.body to be shipped at %s: %v", te.level, te.shipped)
+		}
+		if res["request.path"] != "/" {
+			t.Errorf("expected request.path to be '%s' but got '%s'", "/", res["request.path"])
+		}
+		if _, ok := entry.Data["request.body"]; !ok {
+			t.Error("expected request.body to be kept on the entry")
+		}
+	}
+}
+
+func TestFireAppVersion(t *testing.T) {
+	tt := []struct {
+		field    string
+		expected string
+	}{
+		{"", "service.version"},
+		{"app.sha", "app.sha"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "app_version_test",
+			alwaysSentFields: logrus.Fields{},
+			AppVersion:       "1.4.2-3f2a1c",
+			AppVersionField:  te.field,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[te.expected] != "1.4.2-3f2a1c" {
+			t.Errorf("expected %s to be '%s' but got '%s'", te.expected, "1.4.2-3f2a1c", res[te.expected])
+		}
+	}
+}
+
+func TestFireEnvironment(t *testing.T) {
+	tt := []struct {
+		field string
+		key   string
+	}{
+		{"", "deployment.environment"},
+		{"env", "env"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "environment_test",
+			alwaysSentFields: logrus.Fields{},
+			Environment:      "staging",
+			EnvironmentField: te.field,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[te.key] != "staging" {
+			t.Errorf("expected %s to be '%s' but got '%s'", te.key, "staging", res[te.key])
+		}
+	}
+}
+
+func TestFireRecordWaitDuration(t *testing.T) {
+	for _, record := range []bool{true, false} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:               conn,
+			appName:            "wait_duration_test",
+			alwaysSentFields:   logrus.Fields{},
+			RecordWaitDuration: record,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		duration, ok := res["@wait_duration_ms"].(float64)
+		if ok != record {
+			t.Errorf("expected @wait_duration_ms to be present '%v' but got '%v'", record, res["@wait_duration_ms"])
+		}
+		if duration < 0 {
+			t.Errorf("expected @wait_duration_ms to be non-negative but got '%v'", duration)
+		}
+	}
+}
+
+func TestFireRecordWaitDurationSlowWriter(t *testing.T) {
+	writes := make(chanWriter)
+	hook := &Hook{
+		conn:               writes,
+		appName:            "wait_duration_test",
+		alwaysSentFields:   logrus.Fields{},
+		RecordWaitDuration: true,
+	}
+	for i := 0; i < 2; i++ {
+		go hook.Fire(&logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel})
+	}
+	// The first write blocks until received, holding up the second entry.
+	time.Sleep(50 * time.Millisecond)
+
+	var longest float64
+	for i := 0; i < 2; i++ {
+		var res map[string]interface{}
+		if err := json.Unmarshal(<-writes, &res); err != nil {
+			t.Fatal(err)
+		}
+		if duration, _ := res["@wait_duration_ms"].(float64); duration > longest {
+			longest = duration
+		}
+	}
+	if longest < 25 {
+		t.Errorf("expected an entry to wait behind the slow write but got '%v'ms", longest)
+	}
+}
+
+func TestFireRecordLoggerLevel(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.WarnLevel
+
+	tt := []struct {
+		levelFunc func() logrus.Level
+		expected  string
+	}{
+		{func() logrus.Level { return logrus.DebugLevel }, "debug"},
+		{nil, "warning"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:              conn,
+			appName:           "logger_level_test",
+			alwaysSentFields:  logrus.Fields{},
+			RecordLoggerLevel: true,
+			LoggerLevelFunc:   te.levelFunc,
+		}
+		entry := &logrus.Entry{Logger: logger, Message: "hello world!", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["logger_level"] != te.expected {
+			t.Errorf("expected logger_level to be '%s' but got '%s'", te.expected, res["logger_level"])
+		}
+	}
+}
+
+func TestFireEmitDataset(t *testing.T) {
+	tt := []struct {
+		dataset  string
+		expected string
+	}{
+		{"", "dataset_test"},
+		{"billing.audit", "billing.audit"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "dataset_test",
+			alwaysSentFields: logrus.Fields{},
+			EmitDataset:      true,
+			Dataset:          te.dataset,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["event.dataset"] != te.expected {
+			t.Errorf("expected event.dataset to be '%s' but got '%s'", te.expected, res["event.dataset"])
+		}
+	}
+}
+
+func TestFireTypeField(t *testing.T) {
+	tt := []struct {
+		fields   logrus.Fields
+		expected string
+	}{
+		{logrus.Fields{"event_type": "audit"}, "audit"},
+		{logrus.Fields{"event_type": "payment"}, "payment"},
+		{logrus.Fields{"event_type": ""}, "type_test"},
+		{logrus.Fields{"event_type": 42}, "type_test"},
+		{logrus.Fields{}, "type_test"},
+	}
+
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "type_test",
+		alwaysSentFields: logrus.Fields{},
+		TypeField:        "event_type",
+	}
+	for _, te := range tt {
+		entry := &logrus.Entry{Message: "hello world!", Data: te.fields, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["type"] != te.expected {
+			t.Errorf("expected type to be '%s' but got '%v'", te.expected, res["type"])
+		}
+	}
+}
+
+func TestFireLoggerName(t *testing.T) {
+	tt := []struct {
+		field string
+		key   string
+	}{
+		{"", "logger"},
+		{"subsystem", "subsystem"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "logger_name_test",
+			alwaysSentFields: logrus.Fields{},
+			LoggerName:       "payments",
+			LoggerField:      te.field,
+		}
+		for i := 0; i < 2; i++ {
+			entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Error(err)
+			}
+		}
+		dec := json.NewDecoder(conn.buff)
+		for dec.More() {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res[te.key] != "payments" {
+				t.Errorf("expected %s to be '%s' but got '%s'", te.key, "payments", res[te.key])
+			}
+		}
+	}
+}
+
+func TestFireLengthPrefixFraming(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:                conn,
+		appName:             "framing_test",
+		alwaysSentFields:    logrus.Fields{},
+		LengthPrefixFraming: true,
+	}
+	for _, message := range []string{"hello world!", "line one\nline two"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, expected := range []string{"hello world!", "line one\nline two"} {
+		header := conn.buff.Next(4)
+		if len(header) != 4 {
+			t.Fatal("expected a 4-byte length header")
+		}
+		length := binary.BigEndian.Uint32(header)
+		payload := conn.buff.Next(int(length))
+		if len(payload) != int(length) {
+			t.Fatalf("expected payload length to be '%d' but got '%d'", length, len(payload))
+		}
+		var res map[string]string
+		if err := json.Unmarshal(payload, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+		}
+	}
+	if conn.buff.Len() != 0 {
+		t.Errorf("expected no trailing bytes but got '%s'", conn.buff.Bytes())
+	}
+}
+
+func TestFireContentLengthTrailer(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:                 conn,
+		appName:              "trailer_test",
+		alwaysSentFields:     logrus.Fields{},
+		ContentLengthTrailer: true,
+	}
+	for _, message := range []string{"hello world!", "héllo wörld"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(conn.buff.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got %d", len(lines))
+	}
+	for _, line := range lines {
+		i := strings.LastIndex(line, "\t")
+		if i < 0 {
+			t.Fatalf("expected a trailer in '%s'", line)
+		}
+		payload, trailer := line[:i], line[i+1:]
+		if trailer != fmt.Sprint(len(payload)) {
+			t.Errorf("expected trailer to be '%d' but got '%s'", len(payload), trailer)
+		}
+		if !json.Valid([]byte(payload)) {
+			t.Errorf("expected the payload to be JSON but got '%s'", payload)
+		}
+	}
+}
+
+// framingWriter frames every write with a trailing newline.
+type framingWriter struct {
+	buff *bytes.Buffer
+}
+
+func (w framingWriter) Write(b []byte) (int, error) {
+	w.buff.Write(b)
+	w.buff.WriteString("\n")
+	return len(b), nil
+}
+
+func TestFireTrimTrailingNewline(t *testing.T) {
+	for _, trim := range []bool{false, true} {
+		conn := framingWriter{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:                conn,
+			alwaysSentFields:    logrus.Fields{},
+			Formatter:           &logrus.JSONFormatter{},
+			TrimTrailingNewline: trim,
+		}
+		for i := 0; i < 2; i++ {
+			entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if doubled := bytes.Contains(conn.buff.Bytes(), []byte("\n\n")); doubled == trim {
+			t.Errorf("expected double newlines with TrimTrailingNewline %v: %v", trim, !trim)
+		}
+		if lines := bytes.Count(conn.buff.Bytes(), []byte("\n")); trim && lines != 2 {
+			t.Errorf("expected 2 lines but got %d", lines)
+		}
+	}
+}
+
+func TestFireFilter(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "filter_test",
+		alwaysSentFields: logrus.Fields{},
+		Filter: func(entry *logrus.Entry) bool {
+			return entry.Data["path"] != "/healthz"
+		},
+	}
+	for _, path := range []string{"/healthz", "/users", "/healthz", "/orders"} {
+		entry := &logrus.Entry{Message: "request", Data: logrus.Fields{"path": path}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"/users", "/orders"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["path"] != expected {
+			t.Errorf("expected path to be '%s' but got '%s'", expected, res["path"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected filtered entries not to be shipped")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	tt := []struct {
+		bufferSize int
+		expected   []string
+	}{
+		{0, []string{"after"}},
+		{1, []string{"paused 1", "after"}},
+		{5, []string{"paused 1", "paused 2", "after"}},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "pause_test",
+			alwaysSentFields: logrus.Fields{},
+			PauseBufferSize:  te.bufferSize,
+		}
+		hook.Pause()
+		for _, message := range []string{"paused 1", "paused 2"} {
+			entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Error(err)
+			}
+		}
+		if conn.buff.Len() != 0 {
+			t.Errorf("expected no bytes to be written while paused but got '%s'", conn.buff.Bytes())
+		}
+		if err := hook.Resume(); err != nil {
+			t.Error(err)
+		}
+		entry := &logrus.Entry{Message: "after", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+
+		dec := json.NewDecoder(conn.buff)
+		for _, expected := range te.expected {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res["message"] != expected {
+				t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+			}
+		}
+		if dec.More() {
+			t.Error("expected entries beyond the pause buffer to be dropped")
+		}
+	}
+}
+
+func TestPausedFatalShips(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "pause_test",
+		alwaysSentFields: logrus.Fields{},
+		PauseBufferSize:  5,
+	}
+	hook.Pause()
+	for _, level := range []logrus.Level{logrus.InfoLevel, logrus.FatalLevel} {
+		entry := &logrus.Entry{Message: level.String(), Data: logrus.Fields{}, Level: level}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"info", "fatal"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+		}
+	}
+}
+
+func TestResumePriorityLanes(t *testing.T) {
+	tt := []struct {
+		priorityLanes bool
+		expected      []string
+	}{
+		{false, []string{"info 1", "info 2", "info 3", "error 1", "info 4", "error 2"}},
+		{true, []string{"error 1", "error 2", "info 1", "info 2", "info 3", "info 4"}},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "priority_test",
+			alwaysSentFields: logrus.Fields{},
+			PauseBufferSize:  10,
+			PriorityLanes:    te.priorityLanes,
+		}
+		hook.Pause()
+		for _, message := range []string{"info 1", "info 2", "info 3", "error 1", "info 4", "error 2"} {
+			level := logrus.InfoLevel
+			if strings.HasPrefix(message, "error") {
+				level = logrus.ErrorLevel
+			}
+			entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: level}
+			if err := hook.Fire(entry); err != nil {
+				t.Error(err)
+			}
+		}
+		if err := hook.Resume(); err != nil {
+			t.Error(err)
+		}
+
+		dec := json.NewDecoder(conn.buff)
+		for _, expected := range te.expected {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res["message"] != expected {
+				t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+			}
+		}
+	}
+}
+
+// badFormatter fails to format entries with the message "bad".
+type badFormatter struct{}
+
+func (badFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry.Message == "bad" {
+		return nil, fmt.Errorf("cannot format %s", entry.Message)
+	}
+	return (&LogstashFormatter{}).Format(entry)
+}
+
+// panickingFormatter panics formatting any entry.
+type panickingFormatter struct{}
+
+func (panickingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	panic("formatter bug")
+}
+
+func TestFireRecoversFormatterPanics(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "panic_test",
+		alwaysSentFields: logrus.Fields{},
+		Formatter:        panickingFormatter{},
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{"one": 1}, Level: logrus.WarnLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"message":          "hello world!",
+		"level":            "warning",
+		"type":             "panic_test",
+		"@formatter_panic": "formatter bug",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%s' but got '%s'", k, v, res[k])
+		}
+	}
+}
+
+func TestResumeDropsUnformattableEntries(t *testing.T) {
+	registerer := &RegistererMock{counters: map[string]*CounterMock{}, histograms: map[string]*HistogramMock{}}
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:              conn,
+		appName:           "format_error_test",
+		alwaysSentFields:  logrus.Fields{},
+		PauseBufferSize:   10,
+		Formatter:         badFormatter{},
+		MetricsRegisterer: registerer,
+	}
+	hook.Pause()
+	for _, message := range []string{"before", "bad", "after"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Format errors are permanent: the entry is dropped rather than retried,
+	// and doesn't hold back the entries after it.
+	if err := hook.Resume(); err == nil {
+		t.Error("expected Resume to report the format error")
+	}
+	if err := hook.Resume(); err != nil {
+		t.Errorf("expected the unformattable entry to be dropped but got '%v'", err)
+	}
+	if failed := registerer.counters["logstash_hook_failed_total"].count; failed != 1 {
+		t.Errorf("expected the unformattable entry to be counted once but got %d", failed)
+	}
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"before", "after"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+		}
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	// Nothing reads from the other end of the pipe, so writes block until the
+	// connection is closed.
+	client, server := net.Pipe()
+	defer server.Close()
+	hook := &Hook{
+		conn:             client,
+		appName:          "close_test",
+		alwaysSentFields: logrus.Fields{},
+		DedupWindow:      time.Hour,
+		CloseTimeout:     50 * time.Millisecond,
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- hook.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("expected Close to report the undelivered entry")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close to return within the timeout")
+	}
+}
+
+func TestCloseAsync(t *testing.T) {
+	tt := []struct {
+		conn    io.Writer
+		failing bool
+	}{
+		{ConnMock{buff: bytes.NewBufferString("")}, false},
+		{FailingWriter{}, true},
+	}
+
+	for _, te := range tt {
+		hook := &Hook{
+			conn:             te.conn,
+			appName:          "close_async_test",
+			alwaysSentFields: logrus.Fields{},
+			DedupWindow:      time.Hour,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-hook.CloseAsync():
+			if failing := err != nil; failing != te.failing {
+				t.Errorf("expected Close to fail: %v, but got '%v'", te.failing, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected Close to complete")
+		}
+	}
+}
+
+func TestFireLazyFields(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "lazy_fields_test",
+		alwaysSentFields: logrus.Fields{},
+		Filter:           func(entry *logrus.Entry) bool { return entry.Message != "drop" },
+		ContentHashField: "hash",
+	}
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	calls := 0
+	config := func() interface{} {
+		calls++
+		return map[string]interface{}{"replicas": 3}
+	}
+
+	logger.WithField("config", Lazy(config)).Info("drop")
+	if calls != 0 {
+		t.Errorf("expected the closure not to be called for a dropped entry but got %d calls", calls)
+	}
+
+	logger.WithField("config", Lazy(config)).Info("ship")
+	if calls != 1 {
+		t.Errorf("expected the closure to be called once for a shipped entry but got %d calls", calls)
+	}
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"replicas": float64(3)}
+	if !reflect.DeepEqual(expected, res["config"]) {
+		t.Errorf("expected config to be '%v' but got '%v'", expected, res["config"])
+	}
+}
@@ -0,0 +1,132 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LokiFormatter formats entries as the JSON payload of the Grafana Loki push
+// API, `/loki/api/v1/push`, so that a hook with an HTTPEndpoint can ship to
+// Loki. Every entry is a line of the stream labeled with its Labels fields.
+type LokiFormatter struct {
+	// Labels lists the fields whose values label the streams entries are
+	// pushed to, e.g. "app" and "env". Fields an entry doesn't set are left
+	// out of its labels.
+	Labels []string
+
+	// Line formats the lines pushed for entries. Defaults to a
+	// LogstashFormatter.
+	Line logrus.Formatter
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Format formats the entry as a push of a single line.
+func (f *LokiFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return f.FormatBatch([]*logrus.Entry{entry})
+}
+
+// FormatBatch formats the entries as a single push, grouping them into
+// streams by their labels.
+func (f *LokiFormatter) FormatBatch(entries []*logrus.Entry) ([]byte, error) {
+	line := f.Line
+	if line == nil {
+		line = &LogstashFormatter{}
+	}
+
+	push := lokiPush{Streams: []lokiStream{}}
+	streams := make(map[string]int)
+	for _, entry := range entries {
+		b, err := line.Format(entry)
+		if err != nil {
+			return nil, err
+		}
+		value := [2]string{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			string(bytes.TrimSuffix(b, []byte("\n"))),
+		}
+
+		labels, key := f.labels(entry)
+		i, ok := streams[key]
+		if !ok {
+			i = len(push.Streams)
+			streams[key] = i
+			push.Streams = append(push.Streams, lokiStream{Stream: labels})
+		}
+		push.Streams[i].Values = append(push.Streams[i].Values, value)
+	}
+
+	serialized, err := json.Marshal(push)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal Loki push to JSON, %v", err)
+	}
+	return serialized, nil
+}
+
+// labels returns the labels of the entry's stream, and a key identifying it.
+func (f *LokiFormatter) labels(entry *logrus.Entry) (map[string]string, string) {
+	labels := make(map[string]string)
+	for _, name := range f.Labels {
+		if v, ok := entry.Data[name]; ok {
+			labels[name] = fmt.Sprint(v)
+		}
+	}
+
+	return labels, labelsKey(labels)
+}
+
+// labelsKey returns a key identifying the stream with the given labels.
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&key, "%s=%q,", name, labels[name])
+	}
+	return key.String()
+}
+
+// mergeLokiPushes merges pushes formatted by a LokiFormatter into a single
+// one, grouping their lines into streams by their labels as FormatBatch does.
+func mergeLokiPushes(pushes [][]byte) ([]byte, error) {
+	merged := lokiPush{Streams: []lokiStream{}}
+	streams := make(map[string]int)
+	for _, b := range pushes {
+		var push lokiPush
+		if err := json.Unmarshal(b, &push); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal Loki push, %v", err)
+		}
+		for _, stream := range push.Streams {
+			key := labelsKey(stream.Stream)
+			i, ok := streams[key]
+			if !ok {
+				i = len(merged.Streams)
+				streams[key] = i
+				merged.Streams = append(merged.Streams, lokiStream{Stream: stream.Stream})
+			}
+			merged.Streams[i].Values = append(merged.Streams[i].Values, stream.Values...)
+		}
+	}
+
+	serialized, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal Loki push to JSON, %v", err)
+	}
+	return serialized, nil
+}
@@ -0,0 +1,135 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLokiFormatterFormatBatch(t *testing.T) {
+	at := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	entries := []*logrus.Entry{
+		{Message: "one", Data: logrus.Fields{"app": "api", "env": "prod"}, Time: at, Level: logrus.InfoLevel},
+		{Message: "two", Data: logrus.Fields{"app": "worker", "env": "prod"}, Time: at.Add(time.Second), Level: logrus.InfoLevel},
+		{Message: "three", Data: logrus.Fields{"env": "prod", "app": "api"}, Time: at.Add(2 * time.Second), Level: logrus.InfoLevel},
+	}
+	lf := LokiFormatter{Labels: []string{"app", "env"}, Line: &logrus.TextFormatter{}}
+
+	b, err := lf.FormatBatch(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, entry := range entries {
+		line, err := lf.Line.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(bytes.TrimSuffix(line, []byte("\n"))))
+	}
+	expected := map[string]interface{}{
+		"streams": []interface{}{
+			map[string]interface{}{
+				"stream": map[string]interface{}{"app": "api", "env": "prod"},
+				"values": []interface{}{
+					[]interface{}{"1493632800000000000", lines[0]},
+					[]interface{}{"1493632802000000000", lines[2]},
+				},
+			},
+			map[string]interface{}{
+				"stream": map[string]interface{}{"app": "worker", "env": "prod"},
+				"values": []interface{}{
+					[]interface{}{"1493632801000000000", lines[1]},
+				},
+			},
+		},
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected push to be '%v' but got '%v'", expected, res)
+	}
+}
+
+func TestFireLoki(t *testing.T) {
+	received := make(chan lokiPush, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var push lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Error(err)
+		}
+		received <- push
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL+"/loki/api/v1/push", "loki_test")
+	hook.Formatter = &LokiFormatter{Labels: []string{"app"}}
+	hook.WithField("app", "api")
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	push := <-received
+	if len(push.Streams) != 1 || len(push.Streams[0].Values) != 1 {
+		t.Fatalf("expected a single line but got '%v'", push)
+	}
+	if push.Streams[0].Stream["app"] != "api" {
+		t.Errorf("expected app label to be '%s' but got '%s'", "api", push.Streams[0].Stream["app"])
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(push.Streams[0].Values[0][1]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["message"] != "hello world!" {
+		t.Errorf("expected message to be '%s' but got '%v'", "hello world!", line["message"])
+	}
+}
+
+func TestFireLokiBatch(t *testing.T) {
+	received := make(chan lokiPush, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var push lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Error(err)
+		}
+		received <- push
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL+"/loki/api/v1/push", "loki_test")
+	hook.Formatter = &LokiFormatter{Labels: []string{"app"}}
+	hook.BatchSize = 3
+	for _, app := range []string{"api", "worker", "api"} {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{"app": app}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected the batch to be pushed once but got '%d' requests", len(received))
+	}
+
+	push := <-received
+	if len(push.Streams) != 2 {
+		t.Fatalf("expected 2 streams but got '%v'", push)
+	}
+	for i, expected := range []struct {
+		app   string
+		lines int
+	}{{"api", 2}, {"worker", 1}} {
+		if push.Streams[i].Stream["app"] != expected.app || len(push.Streams[i].Values) != expected.lines {
+			t.Errorf("expected stream %d to hold %d lines of app '%s' but got '%v'", i, expected.lines, expected.app, push.Streams[i])
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mappingKinds maps Elasticsearch field types to the kinds of values they
+// accept. Types not listed are not checked.
+var mappingKinds = map[string]string{
+	"text":             "string",
+	"keyword":          "string",
+	"constant_keyword": "string",
+	"wildcard":         "string",
+	"ip":               "string",
+	"long":             "integer",
+	"integer":          "integer",
+	"short":            "integer",
+	"byte":             "integer",
+	"unsigned_long":    "integer",
+	"double":           "number",
+	"float":            "number",
+	"half_float":       "number",
+	"scaled_float":     "number",
+	"boolean":          "boolean",
+	"date":             "date",
+}
+
+type esMapping struct {
+	Mappings   *esMapping           `json:"mappings"`
+	Type       string               `json:"type"`
+	Properties map[string]esMapping `json:"properties"`
+}
+
+// mappingKinds returns the kinds of values accepted by the fields of the
+// MappingJSON, parsed once, keyed by their dotted path.
+func (h *Hook) mappingKinds() (map[string]string, error) {
+	h.mappingOnce.Do(func() {
+		var mapping esMapping
+		if err := json.Unmarshal(h.MappingJSON, &mapping); err != nil {
+			h.mappingErr = fmt.Errorf("Failed to parse mapping JSON, %v", err)
+			return
+		}
+		if mapping.Mappings != nil {
+			mapping = *mapping.Mappings
+		}
+		h.mapping = make(map[string]string)
+		addMappingKinds(h.mapping, "", mapping.Properties)
+	})
+	return h.mapping, h.mappingErr
+}
+
+func addMappingKinds(kinds map[string]string, prefix string, properties map[string]esMapping) {
+	for name, property := range properties {
+		if kind, ok := mappingKinds[property.Type]; ok {
+			kinds[prefix+name] = kind
+		}
+		addMappingKinds(kinds, prefix+name+".", property.Properties)
+	}
+}
+
+// applyMapping checks the fields of the entry against the MappingJSON. Strings
+// holding a value of the mapped type are coerced to it; other mismatching
+// fields are dropped, and reported to OnError.
+func (h *Hook) applyMapping(entry *logrus.Entry) (*logrus.Entry, error) {
+	kinds, err := h.mappingKinds()
+	if err != nil {
+		return entry, err
+	}
+
+	checked := entry
+	for key, value := range entry.Data {
+		kind, ok := kinds[key]
+		if !ok || matchesKind(value, kind) {
+			continue
+		}
+		if checked == entry {
+			checked = copyEntry(entry)
+		}
+		if coerced, ok := coerceKind(value, kind); ok {
+			checked.Data[key] = coerced
+			continue
+		}
+		delete(checked.Data, key)
+		if h.OnError != nil {
+			h.reportError(fmt.Errorf("Dropped field %q, %T does not match mapped type %s", key, value, kind))
+		}
+	}
+	return checked, nil
+}
+
+// matchesKind reports whether value is of the given kind.
+func matchesKind(value interface{}, kind string) bool {
+	switch value.(type) {
+	case nil, *LazyValue:
+		// Lazy fields are not evaluated before formatting.
+		return true
+	case time.Time:
+		return kind == "date"
+	case json.Number:
+		return kind == "integer" || kind == "number"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return kind == "string" || kind == "date"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return kind == "integer" || kind == "number" || kind == "date"
+	case reflect.Float32, reflect.Float64:
+		return kind == "number"
+	case reflect.Bool:
+		return kind == "boolean"
+	}
+	// Other values, such as errors or Stringers, are formatted as strings.
+	return kind == "string"
+}
+
+// coerceKind converts a string value to the given kind, if it holds one.
+func coerceKind(value interface{}, kind string) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	switch kind {
+	case "integer":
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
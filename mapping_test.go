@@ -0,0 +1,79 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const testMapping = `{
+	"mappings": {
+		"properties": {
+			"status": {"type": "integer"},
+			"user": {"type": "keyword"},
+			"http": {
+				"properties": {
+					"latency": {"type": "float"},
+					"cached": {"type": "boolean"}
+				}
+			}
+		}
+	}
+}`
+
+func TestFireMappingJSON(t *testing.T) {
+	var reported []error
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "mapping_test",
+		alwaysSentFields: logrus.Fields{},
+		MappingJSON:      []byte(testMapping),
+		OnError: func(err error, suppressed int) {
+			reported = append(reported, err)
+		},
+	}
+	data := logrus.Fields{
+		"status":       "not found",
+		"user":         "mick",
+		"http.latency": "0.25",
+		"http.cached":  true,
+		"other":        "untouched",
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: data, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res["status"]; ok {
+		t.Errorf("expected status to be dropped but got '%v'", res["status"])
+	}
+	expected := map[string]interface{}{
+		"user":         "mick",
+		"http.latency": 0.25,
+		"http.cached":  true,
+		"other":        "untouched",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%v' but got '%v'", k, v, res[k])
+		}
+	}
+	if len(reported) != 1 {
+		t.Errorf("expected the dropped field to be reported once but got '%v'", reported)
+	}
+	if data["status"] != "not found" {
+		t.Error("expected the entry's own fields to be left untouched")
+	}
+
+	hook = &Hook{conn: conn, alwaysSentFields: logrus.Fields{}, MappingJSON: []byte(`{"mappings": `)}
+	if err := hook.Fire(&logrus.Entry{Message: "hello world!", Data: logrus.Fields{}}); err == nil {
+		t.Error("expected Fire to fail with an invalid mapping")
+	}
+}
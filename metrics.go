@@ -0,0 +1,68 @@
+package logrus_logstash
+
+import "time"
+
+// MetricsRegisterer creates the metrics the hook reports on. It is a thin
+// interface, e.g. over a prometheus.Registerer, so that the hook doesn't depend
+// on a metrics library.
+type MetricsRegisterer interface {
+	// NewCounter creates and registers a counter.
+	NewCounter(name, help string) Counter
+	// NewHistogram creates and registers a histogram.
+	NewHistogram(name, help string) Histogram
+}
+
+// Counter is a metric which only goes up, such as a prometheus.Counter.
+type Counter interface {
+	Inc()
+}
+
+// Histogram samples observations, such as a prometheus.Histogram.
+type Histogram interface {
+	Observe(float64)
+}
+
+// hookMetrics are the metrics reported by a hook.
+type hookMetrics struct {
+	sent         Counter
+	failed       Counter
+	dropped      Counter
+	writeSeconds Histogram
+}
+
+// metrics returns the hook's metrics, registering them on first use, or nil
+// if the hook has no MetricsRegisterer.
+func (h *Hook) metrics() *hookMetrics {
+	if h.MetricsRegisterer == nil {
+		return nil
+	}
+	h.metricsOnce.Do(func() {
+		r := h.MetricsRegisterer
+		h.hookMetrics = &hookMetrics{
+			sent:         r.NewCounter("logstash_hook_sent_total", "Number of entries shipped to Logstash."),
+			failed:       r.NewCounter("logstash_hook_failed_total", "Number of entries which failed to ship to Logstash."),
+			dropped:      r.NewCounter("logstash_hook_dropped_total", "Number of entries dropped before shipping to Logstash."),
+			writeSeconds: r.NewHistogram("logstash_hook_write_seconds", "Time spent writing entries to Logstash."),
+		}
+	})
+	return h.hookMetrics
+}
+
+func (h *Hook) countSent(start time.Time) {
+	if m := h.metrics(); m != nil {
+		m.sent.Inc()
+		m.writeSeconds.Observe(time.Since(start).Seconds())
+	}
+}
+
+func (h *Hook) countFailed() {
+	if m := h.metrics(); m != nil {
+		m.failed.Inc()
+	}
+}
+
+func (h *Hook) countDropped() {
+	if m := h.metrics(); m != nil {
+		m.dropped.Inc()
+	}
+}
@@ -0,0 +1,97 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type CounterMock struct {
+	count int
+}
+
+func (c *CounterMock) Inc() {
+	c.count++
+}
+
+type HistogramMock struct {
+	observations []float64
+}
+
+func (h *HistogramMock) Observe(v float64) {
+	h.observations = append(h.observations, v)
+}
+
+type RegistererMock struct {
+	counters   map[string]*CounterMock
+	histograms map[string]*HistogramMock
+}
+
+func (r *RegistererMock) NewCounter(name, help string) Counter {
+	c := &CounterMock{}
+	r.counters[name] = c
+	return c
+}
+
+func (r *RegistererMock) NewHistogram(name, help string) Histogram {
+	h := &HistogramMock{}
+	r.histograms[name] = h
+	return h
+}
+
+type FailingWriter struct{}
+
+func (FailingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestFireMetrics(t *testing.T) {
+	registerer := &RegistererMock{counters: map[string]*CounterMock{}, histograms: map[string]*HistogramMock{}}
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:              conn,
+		appName:           "metrics_test",
+		alwaysSentFields:  logrus.Fields{},
+		LevelConns:        map[logrus.Level]io.Writer{logrus.ErrorLevel: FailingWriter{}},
+		Filter:            func(entry *logrus.Entry) bool { return entry.Message != "drop" },
+		MetricsRegisterer: registerer,
+	}
+	entries := []*logrus.Entry{
+		{Message: "ship", Data: logrus.Fields{}, Level: logrus.InfoLevel},
+		{Message: "ship", Data: logrus.Fields{}, Level: logrus.InfoLevel},
+		{Message: "fail", Data: logrus.Fields{}, Level: logrus.ErrorLevel},
+		{Message: "drop", Data: logrus.Fields{}, Level: logrus.InfoLevel},
+	}
+	for _, entry := range entries {
+		hook.Fire(entry)
+	}
+
+	tt := []struct {
+		name     string
+		expected int
+	}{
+		{"logstash_hook_sent_total", 2},
+		{"logstash_hook_failed_total", 1},
+		{"logstash_hook_dropped_total", 1},
+	}
+	for _, te := range tt {
+		c, ok := registerer.counters[te.name]
+		if !ok {
+			t.Errorf("expected counter '%s' to be registered", te.name)
+			continue
+		}
+		if c.count != te.expected {
+			t.Errorf("expected counter '%s' to be '%d' but got '%d'", te.name, te.expected, c.count)
+		}
+	}
+	h, ok := registerer.histograms["logstash_hook_write_seconds"]
+	if !ok {
+		t.Fatal("expected histogram 'logstash_hook_write_seconds' to be registered")
+	}
+	if len(h.observations) != 2 {
+		t.Errorf("expected '%d' write latency observations but got '%d'", 2, len(h.observations))
+	}
+}
@@ -0,0 +1,119 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pooled reports whether entries of the given level ship over the hook's pool
+// of connections.
+func (h *Hook) pooled(level logrus.Level) bool {
+	if h.PoolSize <= 1 || !h.redials() || h.Durable {
+		return false
+	}
+	return h.levelConn(level) == nil && h.syslog == nil && h.HTTPEndpoint == ""
+}
+
+// connPool returns the hook's pool of connections, created on first use with
+// the hook's connection, if any, and empty slots dialed once leased.
+func (h *Hook) connPool() chan io.Writer {
+	h.poolOnce.Do(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		conn := h.conn
+		if conn != nil && !h.headerSent && h.writeHeader(conn) != nil {
+			if closer, ok := conn.(io.Closer); ok {
+				closer.Close()
+			}
+			conn = nil
+		}
+		h.pool = make(chan io.Writer, h.PoolSize)
+		h.pool <- conn
+		h.conn = nil
+		for i := 1; i < h.PoolSize; i++ {
+			h.pool <- nil
+		}
+	})
+	return h.pool
+}
+
+// shipPooled writes the entry over a connection leased from the pool,
+// dialing it first if need be. A failed connection is dropped, to be dialed
+// again by the next Fire leasing its slot.
+func (h *Hook) shipPooled(entry *logrus.Entry, started time.Time) error {
+	pool := h.connPool()
+	conn := <-pool
+	defer func() {
+		pool <- conn
+	}()
+
+	if conn == nil {
+		h.jitter()
+		h.mu.Lock()
+		dialed, err := h.reconnect()
+		h.mu.Unlock()
+		if err != nil {
+			h.setHealthy(false)
+			h.countFailed()
+			return err
+		}
+		conn = dialed
+	}
+
+	h.recordWaitDuration(entry, started)
+	data, formatted, err := h.encode(entry)
+	if err != nil {
+		h.countFailed()
+		return err
+	}
+	start := time.Now()
+	if err := write(conn, data); err != nil {
+		if closer, ok := conn.(io.Closer); ok {
+			closer.Close()
+		}
+		conn = nil
+		h.setHealthy(false)
+		h.countFailed()
+		return err
+	}
+	h.setHealthy(true)
+	h.countSent(start)
+	h.remember(formatted)
+	return nil
+}
+
+// closePool closes the pooled connections, waiting up to timeout for those in
+// use. Their slots are emptied, to be dialed again if the hook is used after
+// closing.
+func (h *Hook) closePool(timeout time.Duration) error {
+	h.mu.Lock()
+	pool := h.pool
+	h.mu.Unlock()
+	if pool == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	closed := 0
+	for closed < cap(pool) && err == nil {
+		select {
+		case conn := <-pool:
+			if closer, ok := conn.(io.Closer); ok {
+				closer.Close()
+			}
+			closed++
+		case <-timer.C:
+			err = fmt.Errorf("Closed before writes completed, %d pooled connections left open", cap(pool)-closed)
+		}
+	}
+	for i := 0; i < closed; i++ {
+		pool <- nil
+	}
+	return err
+}
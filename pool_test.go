@@ -0,0 +1,113 @@
+package logrus_logstash
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// poolServer accepts connections on ln and sends every line read from them to
+// lines, until ln is closed.
+func poolServer(ln net.Listener, lines chan<- string, accepted *int64) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(accepted, 1)
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+	}
+}
+
+func TestFirePoolSize(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	const goroutines, perGoroutine = 8, 50
+	lines := make(chan string, goroutines*perGoroutine)
+	var accepted int64
+	go poolServer(ln, lines, &accepted)
+
+	hook := NewLazyHook("tcp", ln.Addr().String(), "pool_test")
+	hook.PoolSize = 4
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				entry := &logrus.Entry{Message: fmt.Sprintf("%d-%d", g, i), Data: logrus.Fields{}, Level: logrus.InfoLevel}
+				if err := hook.Fire(entry); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for len(seen) < goroutines*perGoroutine {
+		var res map[string]string
+		if err := json.Unmarshal([]byte(<-lines), &res); err != nil {
+			t.Fatal(err)
+		}
+		if seen[res["message"]] {
+			t.Errorf("expected %s to arrive once", res["message"])
+		}
+		seen[res["message"]] = true
+	}
+	if n := atomic.LoadInt64(&accepted); n < 1 || n > int64(hook.PoolSize) {
+		t.Errorf("expected between 1 and %d connections but got %d", hook.PoolSize, n)
+	}
+}
+
+func benchmarkPoolSize(b *testing.B, size int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	hook := NewLazyHook("tcp", ln.Addr().String(), "pool_bench")
+	hook.PoolSize = size
+	defer hook.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{"one": 1}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPoolSize1(b *testing.B) { benchmarkPoolSize(b, 1) }
+func BenchmarkPoolSize8(b *testing.B) { benchmarkPoolSize(b, 8) }
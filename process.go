@@ -0,0 +1,131 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// processStart holds the monotonic clock reading MonotonicField counts
+	// from.
+	processStart = time.Now()
+
+	processInfoOnce sync.Once
+	processInfo     logrus.Fields
+
+	// osHostname and readBuildInfo are swapped in tests.
+	osHostname    = os.Hostname
+	readBuildInfo = debug.ReadBuildInfo
+)
+
+// kubernetesEnv maps the environment variables set from the downward API to
+// the fields they are added under.
+var kubernetesEnv = []struct {
+	env   string
+	field string
+}{
+	{"POD_NAME", "k8s.pod.name"},
+	{"POD_NAMESPACE", "k8s.namespace"},
+	{"NODE_NAME", "k8s.node.name"},
+}
+
+// processFields returns the fields describing the current process, resolved
+// once.
+func processFields() logrus.Fields {
+	processInfoOnce.Do(func() {
+		processInfo = logrus.Fields{
+			"process.pid":  os.Getpid(),
+			"process.name": filepath.Base(os.Args[0]),
+		}
+		if executable, err := os.Executable(); err == nil {
+			processInfo["process.executable"] = executable
+		}
+	})
+	return processInfo
+}
+
+// buildInfoFields returns the version and VCS revision of the main module,
+// resolved once, or none if the binary carries no build info.
+func (h *Hook) buildInfoFields() logrus.Fields {
+	h.buildInfoOnce.Do(func() {
+		h.buildInfo = logrus.Fields{}
+		info, ok := readBuildInfo()
+		if !ok {
+			return
+		}
+		if v := info.Main.Version; v != "" && v != "(devel)" {
+			h.buildInfo["service.version"] = v
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				h.buildInfo["service.revision"] = setting.Value
+			}
+		}
+	})
+	return h.buildInfo
+}
+
+// hostname returns the Hostname if set, or else the name of the host,
+// resolved once.
+func (h *Hook) hostname() string {
+	if h.Hostname != "" {
+		return h.Hostname
+	}
+	h.hostnameOnce.Do(func() {
+		h.resolvedHostname, _ = osHostname()
+	})
+	return h.resolvedHostname
+}
+
+// kubernetesFields returns the pod metadata fields of the hook, read once from
+// the environment.
+func (h *Hook) kubernetesFields() logrus.Fields {
+	h.kubernetesOnce.Do(func() {
+		h.kubernetes = logrus.Fields{}
+		for _, k := range kubernetesEnv {
+			if v := os.Getenv(k.env); v != "" {
+				h.kubernetes[k.field] = v
+			}
+		}
+	})
+	return h.kubernetes
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from the header
+// of its stack trace, e.g. "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// monotonicNanos returns the nanoseconds elapsed since processStart, or one
+// more than the previous value returned to the hook if the clock didn't move
+// since.
+func (h *Hook) monotonicNanos() int64 {
+	now := int64(time.Since(processStart))
+	for {
+		last := atomic.LoadInt64(&h.monotonic)
+		next := now
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&h.monotonic, last, next) {
+			return next
+		}
+	}
+}
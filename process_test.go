@@ -0,0 +1,275 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireProcessInfo(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:               conn,
+		appName:            "process_test",
+		alwaysSentFields:   logrus.Fields{},
+		IncludeProcessInfo: true,
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["process.pid"] != float64(os.Getpid()) {
+		t.Errorf("expected process.pid to be '%d' but got '%v'", os.Getpid(), res["process.pid"])
+	}
+	for _, key := range []string{"process.name", "process.executable"} {
+		if v, ok := res[key].(string); !ok || v == "" {
+			t.Errorf("expected %s to be not empty", key)
+		}
+	}
+}
+
+func TestFireHostname(t *testing.T) {
+	osHostname = func() (string, error) {
+		return "3f2a1c9b7d4e", nil
+	}
+	defer func() {
+		osHostname = os.Hostname
+	}()
+	tt := []struct {
+		hostname string
+		field    string
+		key      string
+		expected string
+	}{
+		{"", "", "hostname", "3f2a1c9b7d4e"},
+		{"node-1", "", "hostname", "node-1"},
+		{"node-1", "host.name", "host.name", "node-1"},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "hostname_test",
+			alwaysSentFields: logrus.Fields{},
+			IncludeHostname:  true,
+			Hostname:         te.hostname,
+			HostnameField:    te.field,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[te.key] != te.expected {
+			t.Errorf("expected %s to be '%s' but got '%v'", te.key, te.expected, res[te.key])
+		}
+	}
+}
+
+func TestFireGoroutineID(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:               conn,
+		appName:            "goroutine_test",
+		alwaysSentFields:   logrus.Fields{},
+		IncludeGoroutineID: true,
+	}
+
+	for i := 0; i < 2; i++ {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Error(err)
+			}
+		}()
+		wg.Wait()
+	}
+
+	ids := map[float64]bool{}
+	dec := json.NewDecoder(conn.buff)
+	for dec.More() {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		id, ok := res["goroutine.id"].(float64)
+		if !ok || id == 0 {
+			t.Errorf("expected goroutine.id to be set but got '%v'", res["goroutine.id"])
+		}
+		ids[id] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected 2 distinct goroutine ids but got %d", len(ids))
+	}
+}
+
+func TestFireMonotonicField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "monotonic_test",
+		alwaysSentFields: logrus.Fields{},
+		MonotonicField:   "@monotonic_ns",
+	}
+	for i := 0; i < 100; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var last int64
+	dec := json.NewDecoder(conn.buff)
+	dec.UseNumber()
+	for dec.More() {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		n, _ := res["@monotonic_ns"].(json.Number)
+		ns, err := n.Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ns <= last {
+			t.Errorf("expected @monotonic_ns to be greater than '%d' but got '%d'", last, ns)
+		}
+		last = ns
+	}
+}
+
+func TestFireSourceHostField(t *testing.T) {
+	osHostname = func() (string, error) {
+		return "3f2a1c9b7d4e", nil
+	}
+	defer func() {
+		osHostname = os.Hostname
+	}()
+
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "source_host_test",
+		alwaysSentFields: logrus.Fields{},
+		SourceHostField:  "@source_host",
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["@source_host"] != "3f2a1c9b7d4e" {
+		t.Errorf("expected @source_host to be '%s' but got '%v'", "3f2a1c9b7d4e", res["@source_host"])
+	}
+	if _, ok := res["hostname"]; ok {
+		t.Error("expected hostname not to be set without IncludeHostname")
+	}
+}
+
+func TestFireKubernetesMetadata(t *testing.T) {
+	tt := []struct {
+		env      map[string]string
+		expected map[string]interface{}
+	}{
+		{
+			map[string]string{"POD_NAME": "billing-7d9f", "POD_NAMESPACE": "payments", "NODE_NAME": "node-3"},
+			map[string]interface{}{"k8s.pod.name": "billing-7d9f", "k8s.namespace": "payments", "k8s.node.name": "node-3"},
+		},
+		{
+			map[string]string{"POD_NAME": "billing-7d9f", "POD_NAMESPACE": "", "NODE_NAME": ""},
+			map[string]interface{}{"k8s.pod.name": "billing-7d9f"},
+		},
+	}
+
+	for _, te := range tt {
+		for k, v := range te.env {
+			t.Setenv(k, v)
+		}
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:               conn,
+			appName:            "kubernetes_test",
+			alwaysSentFields:   logrus.Fields{},
+			KubernetesMetadata: true,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"k8s.pod.name", "k8s.namespace", "k8s.node.name"} {
+			if res[key] != te.expected[key] {
+				t.Errorf("expected %s to be '%v' but got '%v'", key, te.expected[key], res[key])
+			}
+		}
+	}
+}
+
+func TestFireBuildInfo(t *testing.T) {
+	defer func() {
+		readBuildInfo = debug.ReadBuildInfo
+	}()
+	tt := []struct {
+		info     *debug.BuildInfo
+		expected map[string]interface{}
+	}{
+		{&debug.BuildInfo{
+			Main:     debug.Module{Path: "example.com/billing", Version: "v1.4.2"},
+			Settings: []debug.BuildSetting{{Key: "vcs", Value: "git"}, {Key: "vcs.revision", Value: "3f2a1c9b"}},
+		}, map[string]interface{}{"service.version": "v1.4.2", "service.revision": "3f2a1c9b"}},
+		{&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/billing", Version: "(devel)"},
+		}, map[string]interface{}{}},
+		{nil, map[string]interface{}{}},
+	}
+
+	for _, te := range tt {
+		info := te.info
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return info, info != nil
+		}
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "build_info_test",
+			alwaysSentFields: logrus.Fields{},
+			IncludeBuildInfo: true,
+		}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"service.version", "service.revision"} {
+			if res[key] != te.expected[key] {
+				t.Errorf("expected %s to be '%v' but got '%v'", key, te.expected[key], res[key])
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package logrus_logstash
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProtoFormatter formats entries as Protocol Buffers messages, for
+// collectors reading length-prefixed protobuf streams. The message schema is
+// the user's: Marshal converts entries to their message and marshals it, e.g.
+//
+//	func(entry *logrus.Entry) ([]byte, error) {
+//		return proto.Marshal(&pb.Event{Message: entry.Message})
+//	}
+//
+// Like other formatters it follows messages with a newline, which hooks with
+// LengthPrefixFraming set replace by the length header the stream needs.
+type ProtoFormatter struct {
+	Marshal func(*logrus.Entry) ([]byte, error)
+}
+
+// Format marshals the entry with Marshal.
+func (f *ProtoFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if f.Marshal == nil {
+		return nil, errors.New("ProtoFormatter has no Marshal func")
+	}
+	b, err := f.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal entry to protobuf, %v", err)
+	}
+	return append(b, '\n'), nil
+}
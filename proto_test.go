@@ -0,0 +1,121 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sampleEvent is a protobuf message with the string fields message = 1 and
+// level = 2, and the uint64 field count = 3, hand-coded for lack of
+// generated code.
+type sampleEvent struct {
+	Message string
+	Level   string
+	Count   uint64
+}
+
+func (e *sampleEvent) marshal() []byte {
+	var b []byte
+	for _, f := range []struct {
+		tag   byte
+		value string
+	}{{1, e.Message}, {2, e.Level}} {
+		b = append(b, f.tag<<3|2)
+		b = appendUvarint(b, uint64(len(f.value)))
+		b = append(b, f.value...)
+	}
+	b = append(b, 3<<3)
+	return appendUvarint(b, e.Count)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func (e *sampleEvent) unmarshal(b []byte) error {
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		key, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case 1<<3 | 2, 2<<3 | 2:
+			s := make([]byte, v)
+			if _, err := io.ReadFull(r, s); err != nil {
+				return err
+			}
+			if key>>3 == 1 {
+				e.Message = string(s)
+			} else {
+				e.Level = string(s)
+			}
+		case 3 << 3:
+			e.Count = v
+		default:
+			return errors.New("unexpected field")
+		}
+	}
+	return nil
+}
+
+func TestProtoFormatter(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "proto_test",
+		alwaysSentFields: logrus.Fields{},
+		Formatter: &ProtoFormatter{Marshal: func(entry *logrus.Entry) ([]byte, error) {
+			count, _ := entry.Data["count"].(int)
+			e := sampleEvent{Message: entry.Message, Level: entry.Level.String(), Count: uint64(count)}
+			return e.marshal(), nil
+		}},
+		LengthPrefixFraming: true,
+	}
+
+	// A count of 10 ends the message with a newline byte, which framing
+	// must keep.
+	expected := []sampleEvent{
+		{"hello world!", "info", 10},
+		{"goodbye", "warning", 300},
+	}
+	for _, e := range expected {
+		level, _ := logrus.ParseLevel(e.Level)
+		entry := &logrus.Entry{Message: e.Message, Data: logrus.Fields{"count": int(e.Count)}, Level: level}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, e := range expected {
+		var size uint32
+		if err := binary.Read(conn.buff, binary.BigEndian, &size); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(conn.buff, b); err != nil {
+			t.Fatal(err)
+		}
+		var res sampleEvent
+		if err := res.unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e, res) {
+			t.Errorf("expected event to be '%v' but got '%v'", e, res)
+		}
+	}
+	if conn.buff.Len() != 0 {
+		t.Errorf("expected no trailing bytes but got '%v'", conn.buff.Bytes())
+	}
+}
@@ -0,0 +1,161 @@
+package logrus_logstash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol values, see RFC 1928 and RFC 1929.
+const (
+	socks5Version          = 5
+	socks5NoAuth           = 0
+	socks5PasswordAuth     = 2
+	socks5NoAcceptable     = 0xff
+	socks5Connect          = 1
+	socks5IPv4             = 1
+	socks5Domain           = 3
+	socks5IPv6             = 4
+	socks5PasswordVersion  = 1
+	socks5PasswordAccepted = 0
+)
+
+// proxyTimeout bounds the time spent dialing and negotiating with a proxy,
+// which happens with the hook's lock held.
+var proxyTimeout = 10 * time.Second
+
+// dialSOCKS5 dials address over TCP through the SOCKS5 proxy at proxyURL,
+// authenticating with the user and password of the URL if any.
+func dialSOCKS5(proxyURL, address string) (net.Conn, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse proxy URL, %v", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("Unsupported proxy scheme %q", u.Scheme)
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid port %q", portStr)
+	}
+
+	conn, err := net.DialTimeout("tcp", u.Host, proxyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(proxyTimeout))
+	if err := socks5Handshake(conn, u.User, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to connect through proxy %s, %v", u.Host, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// socks5Handshake authenticates with the proxy on conn and asks it to connect
+// to host:port.
+func socks5Handshake(conn net.Conn, user *url.Userinfo, host string, port uint16) error {
+	methods := []byte{socks5NoAuth}
+	if user != nil {
+		methods = []byte{socks5PasswordAuth}
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5PasswordAuth:
+		if err := socks5Authenticate(conn, user); err != nil {
+			return err
+		}
+	case socks5NoAcceptable:
+		return errors.New("no acceptable authentication method")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		request = append(request, socks5Domain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5IPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5IPv6)
+		request = append(request, ip...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("connect failed with code %d", header[1])
+	}
+	// Skip the bound address, which the hook has no use for
+	var skip int
+	switch header[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("unsupported address type %d", header[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+// socks5Authenticate authenticates with the user and password, see RFC 1929.
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	name := user.Username()
+	password, _ := user.Password()
+	if len(name) > 255 || len(password) > 255 {
+		return errors.New("proxy user or password too long")
+	}
+	request := []byte{socks5PasswordVersion, byte(len(name))}
+	request = append(request, name...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != socks5PasswordAccepted {
+		return errors.New("proxy authentication failed")
+	}
+	return nil
+}
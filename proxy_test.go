@@ -0,0 +1,199 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// socks5Server serves SOCKS5 CONNECT requests on ln, requiring the user and
+// password if not empty, and sends the requested addresses to connects.
+func socks5Server(ln net.Listener, user, password string, connects chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			greeting := make([]byte, 2)
+			if _, err := io.ReadFull(conn, greeting); err != nil {
+				return
+			}
+			methods := make([]byte, greeting[1])
+			if _, err := io.ReadFull(conn, methods); err != nil {
+				return
+			}
+			if user == "" {
+				conn.Write([]byte{5, 0})
+			} else {
+				conn.Write([]byte{5, 2})
+				auth := make([]byte, 2)
+				io.ReadFull(conn, auth)
+				name := make([]byte, auth[1])
+				io.ReadFull(conn, name)
+				n := make([]byte, 1)
+				io.ReadFull(conn, n)
+				pass := make([]byte, n[0])
+				io.ReadFull(conn, pass)
+				if string(name) != user || string(pass) != password {
+					conn.Write([]byte{1, 1})
+					return
+				}
+				conn.Write([]byte{1, 0})
+			}
+
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			var host string
+			switch header[3] {
+			case 1:
+				ip := make([]byte, 4)
+				io.ReadFull(conn, ip)
+				host = net.IP(ip).String()
+			case 3:
+				n := make([]byte, 1)
+				io.ReadFull(conn, n)
+				name := make([]byte, n[0])
+				io.ReadFull(conn, name)
+				host = string(name)
+			default:
+				return
+			}
+			port := make([]byte, 2)
+			io.ReadFull(conn, port)
+			address := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+			connects <- address
+
+			target, err := net.Dial("tcp", address)
+			if err != nil {
+				conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer target.Close()
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			io.Copy(target, conn)
+		}(conn)
+	}
+}
+
+func TestNewProxiedHook(t *testing.T) {
+	tt := []struct {
+		user     string
+		password string
+	}{
+		{"", ""},
+		{"alice", "secret"},
+	}
+
+	for _, te := range tt {
+		logstash, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logstash.Close()
+		proxy, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer proxy.Close()
+		connects := make(chan string, 1)
+		go socks5Server(proxy, te.user, te.password, connects)
+
+		proxyURL := "socks5://" + proxy.Addr().String()
+		if te.user != "" {
+			proxyURL = "socks5://" + te.user + ":" + te.password + "@" + proxy.Addr().String()
+		}
+		hook, err := NewProxiedHook("tcp", logstash.Addr().String(), proxyURL, "proxy_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer hook.Close()
+		if address := <-connects; address != logstash.Addr().String() {
+			t.Errorf("expected proxied address to be '%s' but got '%s'", logstash.Addr().String(), address)
+		}
+
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		server, err := logstash.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		var res map[string]string
+		if err := json.NewDecoder(server).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != "hello world!" {
+			t.Errorf("expected message to be '%s' but got '%s'", "hello world!", res["message"])
+		}
+	}
+}
+
+func TestNewProxiedHookErrors(t *testing.T) {
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	go socks5Server(proxy, "alice", "secret", make(chan string, 1))
+
+	tt := []struct {
+		protocol string
+		proxy    string
+	}{
+		{"udp", "socks5://" + proxy.Addr().String()},
+		{"tcp", "http://" + proxy.Addr().String()},
+		{"tcp", "socks5://alice:wrong@" + proxy.Addr().String()},
+	}
+
+	for _, te := range tt {
+		if _, err := NewProxiedHook(te.protocol, "127.0.0.1:5000", te.proxy, "proxy_test"); err == nil {
+			t.Errorf("expected NewProxiedHook to fail for '%s' through '%s'", te.protocol, te.proxy)
+		}
+	}
+}
+
+func TestNewProxiedHookHandshakeTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { proxyTimeout = timeout }(proxyTimeout)
+	proxyTimeout = 50 * time.Millisecond
+
+	// A proxy which accepts connections but never answers.
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	go func() {
+		for {
+			conn, err := proxy.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := NewProxiedHook("tcp", "127.0.0.1:5000", "socks5://"+proxy.Addr().String(), "proxy_test")
+		failed <- err
+	}()
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("expected NewProxiedHook to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the proxy handshake to time out")
+	}
+}
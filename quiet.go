@@ -0,0 +1,67 @@
+package logrus_logstash
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultStartupBufferSize = 1000
+
+// clock returns the current time.
+func (h *Hook) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+// quiet reports whether the entry is held back, or dropped, because the
+// StartupQuietPeriod hasn't elapsed yet. Once it has, the held entries are
+// shipped ahead of the entry.
+func (h *Hook) quiet(entry *logrus.Entry) bool {
+	h.mu.Lock()
+	now := h.clock()
+	if h.started.IsZero() {
+		h.started = now
+		time.AfterFunc(h.StartupQuietPeriod, func() {
+			h.reportError(h.flushStartup())
+		})
+	}
+	if now.Sub(h.started) < h.StartupQuietPeriod {
+		size := h.StartupBufferSize
+		if size <= 0 {
+			size = defaultStartupBufferSize
+		}
+		full := len(h.startup) >= size
+		if !full {
+			h.startup = append(h.startup, h.holdCopy(entry))
+		}
+		h.mu.Unlock()
+
+		if full {
+			h.drop(entry, DropBufferFull)
+		}
+		return true
+	}
+	h.mu.Unlock()
+
+	h.flushStartup()
+	return false
+}
+
+// flushStartup ships the entries held back during the StartupQuietPeriod.
+func (h *Hook) flushStartup() error {
+	h.mu.Lock()
+	startup := h.startup
+	h.startup = nil
+	h.mu.Unlock()
+
+	var firstErr error
+	for _, entry := range startup {
+		if err := h.ship(entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
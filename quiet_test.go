@@ -0,0 +1,72 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireStartupQuietPeriod(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	now := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	hook := &Hook{
+		conn:               conn,
+		appName:            "quiet_test",
+		alwaysSentFields:   logrus.Fields{},
+		StartupQuietPeriod: time.Hour,
+		now:                func() time.Time { return now },
+	}
+	for _, message := range []string{"boot 1", "boot 2"} {
+		entry := &logrus.Entry{Message: message, Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+	if conn.buff.Len() != 0 {
+		t.Fatalf("expected entries to be deferred during the quiet period but got '%s'", conn.buff.Bytes())
+	}
+
+	now = now.Add(time.Hour)
+	entry := &logrus.Entry{Message: "running", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"boot 1", "boot 2", "running"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+		}
+	}
+}
+
+func TestFireStartupQuietPeriodReportsErrors(t *testing.T) {
+	errs := make(chan error, 1)
+	hook := &Hook{
+		conn:               FailingWriter{},
+		appName:            "quiet_test",
+		alwaysSentFields:   logrus.Fields{},
+		StartupQuietPeriod: 10 * time.Millisecond,
+		OnError:            func(err error, suppressed int) { errs <- err },
+	}
+	entry := &logrus.Entry{Message: "boot", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected OnError to get the error of the held entry")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the failed write of the held entry to be reported")
+	}
+}
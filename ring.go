@@ -0,0 +1,44 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+)
+
+// remember keeps the written entry, as formatted by encode, in the ring
+// buffer, in place of the oldest one once RingBufferSize entries are kept.
+func (h *Hook) remember(data []byte) {
+	if data == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.ring) < h.RingBufferSize {
+		h.ring = append(h.ring, data)
+		return
+	}
+	h.ring[h.ringNext] = data
+	h.ringNext = (h.ringNext + 1) % len(h.ring)
+}
+
+// Recent returns the last RingBufferSize entries the hook shipped, oldest
+// first, e.g. for a debug endpoint. Entries formatted as something else than
+// a JSON object are returned with their line under `message`.
+func (h *Hook) Recent() []map[string]interface{} {
+	h.mu.Lock()
+	ring := make([][]byte, 0, len(h.ring))
+	ring = append(ring, h.ring[h.ringNext:]...)
+	ring = append(ring, h.ring[:h.ringNext]...)
+	h.mu.Unlock()
+
+	recent := make([]map[string]interface{}, 0, len(ring))
+	for _, data := range ring {
+		var event map[string]interface{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			event = map[string]interface{}{"message": string(trimNewline(data))}
+		}
+		recent = append(recent, event)
+	}
+	return recent
+}
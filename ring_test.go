@@ -0,0 +1,69 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRecent(t *testing.T) {
+	tt := []struct {
+		fired    int
+		expected []string
+	}{
+		{0, []string{}},
+		{2, []string{"entry 0", "entry 1"}},
+		{3, []string{"entry 0", "entry 1", "entry 2"}},
+		{7, []string{"entry 4", "entry 5", "entry 6"}},
+	}
+
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "ring_test",
+			alwaysSentFields: logrus.Fields{},
+			RingBufferSize:   3,
+		}
+		for i := 0; i < te.fired; i++ {
+			entry := &logrus.Entry{Message: fmt.Sprintf("entry %d", i), Data: logrus.Fields{"i": i}, Level: logrus.InfoLevel}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		recent := hook.Recent()
+		if len(recent) != len(te.expected) {
+			t.Fatalf("expected %d recent entries but got %d", len(te.expected), len(recent))
+		}
+		for i, expected := range te.expected {
+			if recent[i]["message"] != expected {
+				t.Errorf("expected recent entry %d to be '%s' but got '%v'", i, expected, recent[i]["message"])
+			}
+			if recent[i]["type"] != "ring_test" {
+				t.Errorf("expected type to be '%s' but got '%v'", "ring_test", recent[i]["type"])
+			}
+		}
+		if conn.buff.Len() == 0 && te.fired > 0 {
+			t.Error("expected entries to ship as well")
+		}
+	}
+}
+
+func TestRecentSkipsFailedWrites(t *testing.T) {
+	hook := &Hook{
+		conn:             FailingWriter{},
+		appName:          "ring_test",
+		alwaysSentFields: logrus.Fields{},
+		RingBufferSize:   3,
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Fatal("expected Fire to fail")
+	}
+	if recent := hook.Recent(); len(recent) != 0 {
+		t.Errorf("expected no recent entries but got '%v'", recent)
+	}
+}
@@ -0,0 +1,49 @@
+package logrus_logstash
+
+import (
+	"fmt"
+	"os"
+)
+
+// rotateFile rotates the hook's connection if it is a file grown beyond
+// RotateBytes: the file is renamed to `<name>.1`, older files are shifted
+// to `<name>.2` and so on up to RotateKeep, and a new file takes its place.
+func (h *Hook) rotateFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, ok := h.conn.(*os.File)
+	if !ok {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() <= int64(h.RotateBytes) {
+		return err
+	}
+
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", name, h.RotateKeep))
+	for i := h.RotateKeep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", name, i), fmt.Sprintf("%s.%d", name, i+1))
+	}
+	if h.RotateKeep > 0 {
+		err = os.Rename(name, name+".1")
+	} else {
+		err = os.Remove(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	rotated, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, info.Mode().Perm())
+	if err != nil {
+		h.conn = nil
+		return err
+	}
+	h.conn = rotated
+	h.connClosed = false
+	return nil
+}
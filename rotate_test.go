@@ -0,0 +1,86 @@
+package logrus_logstash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireRotateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hook, err := NewHookWithWriter(f, "rotate_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.RotateBytes = 200
+	hook.RotateKeep = 2
+	defer hook.Close()
+	for i := 0; i < 10; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, kept := range []string{name, name + ".1", name + ".2"} {
+		info, err := os.Stat(kept)
+		if err != nil {
+			t.Errorf("expected %s to exist: %v", kept, err)
+			continue
+		}
+		if info.Size() > 200+100 {
+			t.Errorf("expected %s to be rotated but it is %d bytes", kept, info.Size())
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected %s.3 to be pruned", name)
+	}
+}
+
+func TestFireBatchRotateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hook, err := NewHookWithWriter(f, "rotate_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.BatchSize = 2
+	hook.RotateBytes = 200
+	hook.RotateKeep = 2
+	defer hook.Close()
+	for i := 0; i < 10; i++ {
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(name + ".1"); err != nil {
+		t.Errorf("expected %s.1 to exist: %v", name, err)
+	}
+	if info, err := os.Stat(name); err != nil || info.Size() > 200+2*100 {
+		t.Errorf("expected %s to be rotated but got %v, %v", name, info, err)
+	}
+}
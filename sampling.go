@@ -0,0 +1,51 @@
+package logrus_logstash
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultSamplingIdle = time.Minute
+
+// samplingState tracks the entries fired at a level while sampling.
+type samplingState struct {
+	seen int
+	last time.Time
+}
+
+// sampledOut reports whether the entry of the given level is dropped by
+// sampling.
+func (h *Hook) sampledOut(level logrus.Level) bool {
+	if level <= logrus.FatalLevel {
+		return false
+	}
+	idle := h.SamplingIdle
+	if idle <= 0 {
+		idle = defaultSamplingIdle
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sampled == nil {
+		h.sampled = make(map[logrus.Level]*samplingState)
+	}
+	state, ok := h.sampled[level]
+	if !ok {
+		state = &samplingState{}
+		h.sampled[level] = state
+	}
+	now := h.clock()
+	if !state.last.IsZero() && now.Sub(state.last) >= idle {
+		state.seen = 0
+	}
+	state.last = now
+	state.seen++
+
+	burst := h.SamplingBurst[level]
+	if state.seen <= burst {
+		return false
+	}
+	return (state.seen-burst-1)%h.Sampling != 0
+}
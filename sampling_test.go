@@ -0,0 +1,67 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireSamplingBurst(t *testing.T) {
+	now := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "sampling_test",
+		alwaysSentFields: logrus.Fields{},
+		Sampling:         5,
+		SamplingBurst:    map[logrus.Level]int{logrus.ErrorLevel: 3},
+		SamplingIdle:     time.Minute,
+		now:              func() time.Time { return now },
+	}
+	fire := func(level logrus.Level, n int) {
+		for i := 0; i < n; i++ {
+			entry := &logrus.Entry{Message: fmt.Sprint(i), Data: logrus.Fields{}, Level: level}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(time.Second)
+		}
+	}
+	shipped := func() []string {
+		var messages []string
+		dec := json.NewDecoder(conn.buff)
+		for dec.More() {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, res["level"]+":"+res["message"])
+		}
+		return messages
+	}
+
+	fire(logrus.ErrorLevel, 14)
+	fire(logrus.InfoLevel, 6)
+	expected := []string{
+		// The burst, then one in five.
+		"error:0", "error:1", "error:2", "error:3", "error:8", "error:13",
+		// No burst for info.
+		"info:0", "info:5",
+	}
+	if messages := shipped(); !reflect.DeepEqual(expected, messages) {
+		t.Errorf("expected shipped entries to be '%v' but got '%v'", expected, messages)
+	}
+
+	// The burst is available again once the level was idle.
+	now = now.Add(time.Minute)
+	fire(logrus.ErrorLevel, 4)
+	expected = []string{"error:0", "error:1", "error:2", "error:3"}
+	if messages := shipped(); !reflect.DeepEqual(expected, messages) {
+		t.Errorf("expected shipped entries to be '%v' but got '%v'", expected, messages)
+	}
+}
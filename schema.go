@@ -0,0 +1,71 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExpectSchema formats the entry as the hook would ship it and checks that
+// every key of required is present with the JSON type it maps to: "string",
+// "number", "bool", "object", "array" or "null". It is meant for tests
+// asserting that a configured hook emits the expected events.
+func (h *Hook) ExpectSchema(entry *logrus.Entry, required map[string]string) error {
+	entry = copyEntry(entry)
+	if err := h.addContextFields(entry); err != nil {
+		return err
+	}
+	entry = h.limitFields(h.stripLevelFields(entry))
+
+	dataBytes, err := h.format(entry)
+	if err != nil {
+		return err
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(dataBytes, &event); err != nil {
+		return fmt.Errorf("Failed to parse formatted entry as a JSON object, %v", err)
+	}
+
+	keys := make([]string, 0, len(required))
+	for key := range required {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var mismatches []string
+	for _, key := range keys {
+		v, ok := event[key]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is missing", key))
+			continue
+		}
+		if typ := jsonType(v); typ != required[key] {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s, not %s", key, typ, required[key]))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("Entry doesn't match the schema: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// jsonType returns the JSON type of a value decoded by encoding/json.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,50 @@
+package logrus_logstash
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestExpectSchema(t *testing.T) {
+	hook := &Hook{
+		appName:          "schema_test",
+		alwaysSentFields: logrus.Fields{"service": "api"},
+		Formatter:        &LogstashFormatter{Tags: []string{"billing"}},
+	}
+	entry := &logrus.Entry{
+		Message: "hello world!",
+		Data:    logrus.Fields{"status": 200, "cached": true, "request": map[string]string{"path": "/"}},
+		Level:   logrus.InfoLevel,
+	}
+	tt := []struct {
+		required map[string]string
+		match    bool
+	}{
+		{map[string]string{
+			"@timestamp": "string",
+			"message":    "string",
+			"type":       "string",
+			"service":    "string",
+			"status":     "number",
+			"cached":     "bool",
+			"request":    "object",
+			"tags":       "array",
+		}, true},
+		{map[string]string{"status": "string"}, false},
+		{map[string]string{"user": "string"}, false},
+	}
+
+	for _, te := range tt {
+		err := hook.ExpectSchema(entry, te.required)
+		if te.match && err != nil {
+			t.Errorf("expected the entry to match %v but got '%v'", te.required, err)
+		}
+		if !te.match && err == nil {
+			t.Errorf("expected the entry not to match %v", te.required)
+		}
+	}
+	if _, ok := entry.Data["service"]; ok {
+		t.Error("expected the entry not to be modified")
+	}
+}
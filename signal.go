@@ -0,0 +1,51 @@
+package logrus_logstash
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// signalNotify, signalStop and signalRaise are swapped in tests.
+var (
+	signalNotify = signal.Notify
+	signalStop   = signal.Stop
+	signalRaise  = raise
+)
+
+// HandleSignals closes the hook, flushing the entries it holds, when the
+// process receives one of sigs. It defaults to os.Interrupt and SIGTERM, which
+// e.g. Kubernetes sends on pod shutdown. The previous signal handling is then
+// restored, and the signal raised again for it, so that by default the
+// process still exits. The previous signal handling is also restored on
+// Close.
+func (h *Hook) HandleSignals(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signalNotify(ch, sigs...)
+
+	h.goBackground(func(done <-chan struct{}) {
+		defer signalStop(ch)
+		select {
+		case sig := <-ch:
+			// Close waits for this goroutine to return, which stops
+			// handling the signal before it is raised again.
+			go func() {
+				h.Close()
+				signalRaise(sig)
+			}()
+		case <-done:
+		}
+	})
+}
+
+// raise sends sig to the process.
+func raise(sig os.Signal) error {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
@@ -0,0 +1,79 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleSignals(t *testing.T) {
+	notified := make(chan chan<- os.Signal, 1)
+	stopped := make(chan chan<- os.Signal, 1)
+	signalNotify = func(c chan<- os.Signal, sigs ...os.Signal) {
+		notified <- c
+	}
+	signalStop = func(c chan<- os.Signal) {
+		stopped <- c
+	}
+	raised := make(chan os.Signal, 1)
+	signalRaise = func(sig os.Signal) error {
+		raised <- sig
+		return nil
+	}
+	defer func() {
+		signalNotify = signal.Notify
+		signalStop = signal.Stop
+		signalRaise = raise
+	}()
+
+	writes := make(chanWriter, 10)
+	hook := &Hook{
+		appName:          "signals_test",
+		alwaysSentFields: logrus.Fields{},
+		LevelConns:       map[logrus.Level]io.Writer{logrus.InfoLevel: writes},
+		DedupWindow:      time.Hour,
+	}
+	entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	hook.HandleSignals()
+
+	c := <-notified
+	c <- syscall.SIGTERM
+
+	select {
+	case b := <-writes:
+		var res map[string]interface{}
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != "hello world!" {
+			t.Errorf("expected message to be '%s' but got '%v'", "hello world!", res["message"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the held back entry to be flushed on the signal")
+	}
+	select {
+	case s := <-stopped:
+		if s != c {
+			t.Error("expected the signal handler channel to be stopped")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the signal handler to be stopped on Close")
+	}
+	select {
+	case sig := <-raised:
+		if sig != syscall.SIGTERM {
+			t.Errorf("expected '%v' to be raised again but got '%v'", syscall.SIGTERM, sig)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the signal to be raised again once closed")
+	}
+}
@@ -0,0 +1,50 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// holdCopy returns a copy of the entry to be held back and shipped later,
+// with its field values snapshotted if SnapshotFields is set.
+func (h *Hook) holdCopy(entry *logrus.Entry) *logrus.Entry {
+	held := copyEntry(entry)
+	if h.SnapshotFields {
+		for k, v := range held.Data {
+			held.Data[k] = snapshotValue(v)
+		}
+	}
+	return held
+}
+
+// snapshotValue returns a copy of the value which the caller cannot mutate.
+// Values which may share state, such as maps or pointers, are marshaled to
+// JSON right away.
+func snapshotValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, time.Time, *LazyValue:
+		return v
+	case error:
+		return v.Error()
+	case json.Marshaler:
+	case fmt.Stringer:
+		if !isNilPointer(v) {
+			return v.String()
+		}
+	default:
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Struct, reflect.Interface:
+		default:
+			return v
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	return json.RawMessage(b)
+}
@@ -0,0 +1,71 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireSnapshotFields(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          "snapshot_test",
+			alwaysSentFields: logrus.Fields{},
+			PauseBufferSize:  10,
+			SnapshotFields:   snapshot,
+		}
+		hook.Pause()
+		order := map[string]interface{}{"items": []string{"book"}, "total": 12}
+		entry := &logrus.Entry{Message: "checkout", Data: logrus.Fields{"order": order}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		order["items"] = []string{"book", "pen"}
+		order["total"] = 15
+		if err := hook.Resume(); err != nil {
+			t.Fatal(err)
+		}
+
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"items": []interface{}{"book"}, "total": float64(12)}
+		if snapshot != reflect.DeepEqual(expected, res["order"]) {
+			t.Errorf("expected order to be snapshotted: %v, but got '%v'", snapshot, res["order"])
+		}
+	}
+}
+
+func TestFireSnapshotFieldsNilStringer(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "snapshot_test",
+		alwaysSentFields: logrus.Fields{},
+		PauseBufferSize:  10,
+		SnapshotFields:   true,
+	}
+	hook.Pause()
+	entry := &logrus.Entry{Message: "redirect", Data: logrus.Fields{"url": (*url.URL)(nil)}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := res["url"]; !ok || value != nil {
+		t.Errorf("expected url to be null but got '%v'", value)
+	}
+}
@@ -0,0 +1,237 @@
+package logrus_logstash
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultSpoolInterval = 5 * time.Second
+
+// NewDurableHook creates a new hook to a Logstash instance, which listens on
+// `protocol`://`address`, with Durable set to spool entries to spoolPath. It
+// replays right away the entries left in the spool by a previous process,
+// without waiting for the next one to be logged.
+func NewDurableHook(protocol, address, appName, spoolPath string) (*Hook, error) {
+	h := NewLazyHook(protocol, address, appName)
+	h.Durable = true
+	h.SpoolPath = spoolPath
+	h.writeMu.Lock()
+	err := h.openSpool()
+	h.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	h.startSpoolShipper()
+	return h, nil
+}
+
+// spooledRecord is an entry kept in the spool, ready to be written to the
+// writer of its level.
+type spooledRecord struct {
+	level logrus.Level
+	data  []byte
+}
+
+// shipDurable durably appends the entry to the spool file before writing it,
+// along with any record left in the spool by earlier failed writes, including
+// those of a previous process, each to the writer of its level. Records stay
+// in the spool until they are written, also when no writer can be dialed.
+// h.writeMu must be held.
+func (h *Hook) shipDurable(entry *logrus.Entry) error {
+	data, formatted, err := h.encode(entry)
+	if err == nil {
+		err = h.openSpool()
+	}
+	if err == nil {
+		h.startSpoolShipper()
+		record := spooledRecord{level: entry.Level, data: data}
+		if err = appendSpool(h.spool, record); err == nil {
+			h.spooled = append(h.spooled, record)
+		}
+	}
+	if err != nil {
+		h.countFailed()
+		return err
+	}
+
+	start := time.Now()
+	if err := h.drainSpool(); err != nil {
+		h.countFailed()
+		return err
+	}
+	h.countSent(start)
+	h.remember(formatted)
+	return nil
+}
+
+// startSpoolShipper starts writing the records left in the spool every
+// SpoolInterval, until Close is called, so that they ship once Logstash is
+// back even if nothing else is logged.
+func (h *Hook) startSpoolShipper() {
+	h.spoolOnce.Do(func() {
+		interval := h.SpoolInterval
+		if interval <= 0 {
+			interval = defaultSpoolInterval
+		}
+		h.goBackground(func(done <-chan struct{}) {
+			h.reportError(h.shipSpool())
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					h.reportError(h.shipSpool())
+				case <-done:
+					return
+				}
+			}
+		})
+	})
+}
+
+// shipSpool writes the records left in the spool, if any.
+func (h *Hook) shipSpool() error {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	if h.spool == nil || len(h.spooled) == 0 {
+		return nil
+	}
+	return h.drainSpool()
+}
+
+// drainSpool writes the spooled records to the writers of their levels, and
+// removes those written from the spool. Records of levels without a writer,
+// as on a filtering hook, are dropped. h.writeMu must be held.
+func (h *Hook) drainSpool() error {
+	rotate := false
+	for i, record := range h.spooled {
+		writer, isConn, err := h.writerFor(record.level)
+		if err == nil && writer != nil {
+			err = write(writer, record.data)
+			if isConn {
+				h.connWritten(err)
+				rotate = rotate || err == nil
+			}
+		}
+		if err != nil {
+			if i > 0 {
+				h.spooled = h.spooled[i:]
+				h.reportError(h.rewriteSpool())
+			}
+			return err
+		}
+	}
+	h.spooled = nil
+	// Should the truncation be lost in a crash, the records are shipped again
+	// rather than dropped.
+	if err := h.spool.Truncate(0); err != nil {
+		return err
+	}
+	if rotate && h.RotateBytes > 0 {
+		return h.rotateFile()
+	}
+	return nil
+}
+
+// openSpool opens the spool file and reads the records left in it. An
+// incomplete last record, from a write interrupted by a crash, is dropped
+// so that new records can be appended. h.writeMu must be held.
+func (h *Hook) openSpool() error {
+	if h.spool != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(h.SpoolPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	records, complete := parseSpool(b)
+	h.spooled = records
+	if complete < len(b) {
+		return h.rewriteSpool()
+	}
+	f, err := os.OpenFile(h.SpoolPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	h.spool = f
+	return nil
+}
+
+// parseSpool parses the records of a spool file, and returns them along with
+// the length of the complete ones.
+func parseSpool(b []byte) ([]spooledRecord, int) {
+	var records []spooledRecord
+	complete := 0
+	for len(b)-complete >= 4 {
+		n := int(binary.BigEndian.Uint32(b[complete:]))
+		if n < 1 || len(b)-complete < 4+n {
+			break
+		}
+		records = append(records, spooledRecord{
+			level: logrus.Level(b[complete+4]),
+			data:  b[complete+5 : complete+4+n],
+		})
+		complete += 4 + n
+	}
+	return records, complete
+}
+
+// appendSpool appends a record to the spool file and syncs it to disk.
+func appendSpool(f *os.File, record spooledRecord) error {
+	if _, err := f.Write(spoolRecord(nil, record)); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// rewriteSpool replaces the spool file by one holding the spooled records,
+// written to a temporary file renamed over it, so that a crash leaves either
+// the old or the new records in the spool. h.writeMu must be held.
+func (h *Hook) rewriteSpool() error {
+	var b []byte
+	for _, record := range h.spooled {
+		b = spoolRecord(b, record)
+	}
+	tmp := h.SpoolPath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.SpoolPath); err != nil {
+		return err
+	}
+
+	f, err = os.OpenFile(h.SpoolPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if h.spool != nil {
+		h.spool.Close()
+	}
+	h.spool = f
+	return nil
+}
+
+// spoolRecord appends record to b, as its level byte and data, prefixed by
+// their 4-byte big-endian length.
+func spoolRecord(b []byte, record spooledRecord) []byte {
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[:], uint32(1+len(record.data)))
+	header[4] = byte(record.level)
+	return append(append(b, header[:]...), record.data...)
+}
@@ -0,0 +1,262 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireDurable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spoolPath := filepath.Join(dir, "audit.spool")
+
+	// The first process can't reach Logstash and crashes.
+	crashed := &Hook{
+		conn:             FailingWriter{},
+		appName:          "durable_test",
+		alwaysSentFields: logrus.Fields{},
+		Durable:          true,
+		SpoolPath:        spoolPath,
+	}
+	defer crashed.Close()
+	entry := &logrus.Entry{Message: "user deleted", Data: logrus.Fields{}, Level: logrus.WarnLevel}
+	if err := crashed.Fire(entry); err == nil {
+		t.Fatal("expected Fire to fail")
+	}
+	if info, err := os.Stat(spoolPath); err != nil || info.Size() == 0 {
+		t.Fatalf("expected the spool file to retain the entry: %v", err)
+	}
+
+	// The next one replays the spooled entry first.
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "durable_test",
+		alwaysSentFields: logrus.Fields{},
+		Durable:          true,
+		SpoolPath:        spoolPath,
+	}
+	defer hook.Close()
+	entry = &logrus.Entry{Message: "user created", Data: logrus.Fields{}, Level: logrus.WarnLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"user deleted", "user created"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected each entry to be shipped once")
+	}
+	if info, err := os.Stat(spoolPath); err != nil || info.Size() != 0 {
+		t.Errorf("expected the spool file to be emptied once delivered: %v", err)
+	}
+}
+
+func TestNewDurableHookReplaysSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spoolPath := filepath.Join(dir, "audit.spool")
+
+	// A previous process spooled an entry, and crashed while spooling
+	// another.
+	spooled := spoolRecord(nil, spooledRecord{logrus.WarnLevel, []byte(`{"message":"user deleted"}` + "\n")})
+	torn := spoolRecord(nil, spooledRecord{logrus.WarnLevel, []byte(`{"message":"user renamed"}` + "\n")})
+	if err := ioutil.WriteFile(spoolPath, append(spooled, torn[:10]...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string)
+	go func() {
+		server, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		dec := json.NewDecoder(server)
+		for {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				return
+			}
+			received <- res["message"]
+		}
+	}()
+
+	hook, err := NewDurableHook("tcp", ln.Addr().String(), "durable_test", spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	select {
+	case message := <-received:
+		if message != "user deleted" {
+			t.Errorf("expected message to be '%s' but got '%s'", "user deleted", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the spooled entry to be replayed without logging")
+	}
+
+	entry := &logrus.Entry{Message: "user created", Data: logrus.Fields{}, Level: logrus.WarnLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-received; message != "user created" {
+		t.Errorf("expected message to be '%s' but got '%s'", "user created", message)
+	}
+}
+
+func TestFireDurableShipsSpoolInBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn := &FlakyWriter{failures: maxWriteRetries + 1}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "durable_test",
+		alwaysSentFields: logrus.Fields{},
+		Durable:          true,
+		SpoolPath:        filepath.Join(dir, "audit.spool"),
+		SpoolInterval:    10 * time.Millisecond,
+	}
+	entry := &logrus.Entry{Message: "user deleted", Data: logrus.Fields{}, Level: logrus.WarnLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Fatal("expected Fire to fail")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]string
+	if err := json.NewDecoder(&conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "user deleted" {
+		t.Errorf("expected message to be '%s' but got '%s'", "user deleted", res["message"])
+	}
+	if info, err := os.Stat(hook.SpoolPath); err != nil || info.Size() != 0 {
+		t.Errorf("expected the spool file to be emptied once delivered: %v", err)
+	}
+}
+
+func TestFireDurableSpoolsWhenDialFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spoolPath := filepath.Join(dir, "audit.spool")
+
+	// Nothing listens on the address once the listener is closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	hook, err := NewDurableHook("tcp", address, "durable_test", spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &logrus.Entry{Message: "user deleted", Data: logrus.Fields{}, Level: logrus.WarnLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Fatal("expected Fire to fail with an unreachable endpoint")
+	}
+	hook.Close()
+
+	b, err := ioutil.ReadFile(spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := parseSpool(b)
+	if len(records) != 1 {
+		t.Fatalf("expected the spool to retain the entry but got %d records", len(records))
+	}
+	var res map[string]string
+	if err := json.Unmarshal(records[0].data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "user deleted" || records[0].level != logrus.WarnLevel {
+		t.Errorf("expected the spooled warning 'user deleted' but got '%s' at %v", res["message"], records[0].level)
+	}
+}
+
+func TestFireDurableReplaysToLevelConns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstash-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn := &FlakyWriter{failures: maxWriteRetries + 1}
+	errorConn := ConnMock{buff: bytes.NewBufferString("")}
+	hook := &Hook{
+		conn:             conn,
+		appName:          "durable_test",
+		alwaysSentFields: logrus.Fields{},
+		Durable:          true,
+		SpoolPath:        filepath.Join(dir, "audit.spool"),
+		LevelConns:       map[logrus.Level]io.Writer{logrus.ErrorLevel: errorConn},
+	}
+	defer hook.Close()
+	entry := &logrus.Entry{Message: "user deleted", Data: logrus.Fields{}, Level: logrus.InfoLevel}
+	if err := hook.Fire(entry); err == nil {
+		t.Fatal("expected Fire to fail")
+	}
+	entry = &logrus.Entry{Message: "disk full", Data: logrus.Fields{}, Level: logrus.ErrorLevel}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, te := range []struct {
+		buff     *bytes.Buffer
+		expected string
+	}{
+		{&conn.buff, "user deleted"},
+		{errorConn.buff, "disk full"},
+	} {
+		var res map[string]string
+		dec := json.NewDecoder(te.buff)
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != te.expected {
+			t.Errorf("expected message to be '%s' but got '%s'", te.expected, res["message"])
+		}
+		if dec.More() {
+			t.Errorf("expected a single entry along with '%s'", te.expected)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package logrus_logstash
+
+import (
+	"io"
+)
+
+// dialStandby dials a standby connection in the background. Like the hook's
+// own reconnects, the dial is jittered and counts against
+// MaxReconnectAttempts. Callers set dialingStandby first.
+func (h *Hook) dialStandby() {
+	h.goBackground(func(done <-chan struct{}) {
+		h.jitter()
+
+		h.mu.Lock()
+		if err := h.dials.begin(h.clock(), h.MaxReconnectAttempts, h.ReconnectCooldown); err != nil {
+			h.dialingStandby = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+		conn, err := h.dial()
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.dialingStandby = false
+		h.dials.end(err)
+		if err != nil {
+			return
+		}
+		select {
+		case <-done:
+			// Closed while dialing.
+			closeWriter(conn)
+		default:
+			h.standby = conn
+		}
+	})
+}
+
+// failover replaces the hook's connection, which failed to write, by the
+// standby connection, and returns it. It returns nil if there is no standby
+// yet.
+func (h *Hook) failover() io.Writer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.standby == nil {
+		return nil
+	}
+	closeWriter(h.conn)
+	h.conn = h.standby
+	h.standby = nil
+	h.connClosed = false
+	h.headerSent = true
+	return h.conn
+}
+
+// closeStandby closes the standby connection. It must be called with mu held.
+func (h *Hook) closeStandby() {
+	closeWriter(h.standby)
+	h.standby = nil
+}
+
+// closeWriter closes w if it is an io.Closer.
+func closeWriter(w io.Writer) {
+	if closer, ok := w.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,159 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// killableConn is a connection whose writes fail once it is killed.
+type killableConn struct {
+	mu     sync.Mutex
+	buff   bytes.Buffer
+	killed bool
+}
+
+func (c *killableConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.killed {
+		return 0, errors.New("connection reset by peer")
+	}
+	return c.buff.Write(p)
+}
+
+func (c *killableConn) kill() {
+	c.mu.Lock()
+	c.killed = true
+	c.mu.Unlock()
+}
+
+func TestWarmStandby(t *testing.T) {
+	var mu sync.Mutex
+	var conns []*killableConn
+	dialed := make(chan struct{}, 10)
+	factory := func() (io.Writer, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn := &killableConn{}
+		conns = append(conns, conn)
+		dialed <- struct{}{}
+		return conn, nil
+	}
+	hook, err := NewHookWithConnFactory(factory, "standby_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.WarmStandby = true
+	defer hook.Close()
+	<-dialed
+
+	fire := func(i int) {
+		entry := &logrus.Entry{Message: fmt.Sprintf("entry %d", i), Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Errorf("expected entry %d to ship but got '%v'", i, err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		fire(i)
+	}
+	select {
+	case <-dialed:
+	case <-time.After(time.Second):
+		t.Fatal("expected a standby connection to be dialed")
+	}
+
+	mu.Lock()
+	conns[0].kill()
+	mu.Unlock()
+	for i := 5; i < 10; i++ {
+		fire(i)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	i := 0
+	for _, conn := range conns {
+		dec := json.NewDecoder(&conn.buff)
+		for dec.More() {
+			var res map[string]string
+			if err := dec.Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprintf("entry %d", i); res["message"] != expected {
+				t.Errorf("expected message to be '%s' but got '%s'", expected, res["message"])
+			}
+			i++
+		}
+	}
+	if i != 10 {
+		t.Errorf("expected %d entries to be shipped but got %d", 10, i)
+	}
+}
+
+func TestWarmStandbyDialsThroughCircuit(t *testing.T) {
+	var mu sync.Mutex
+	dials := 0
+	factory := func() (io.Writer, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials == 1 {
+			return &killableConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	hook, err := NewHookWithConnFactory(factory, "standby_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.WarmStandby = true
+	hook.MaxReconnectAttempts = 2
+
+	for i := 0; i < 50; i++ {
+		entry := &logrus.Entry{Message: fmt.Sprintf("entry %d", i), Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dials > 1+hook.MaxReconnectAttempts {
+		t.Errorf("Expected at most %d dials, got %d", 1+hook.MaxReconnectAttempts, dials)
+	}
+}
+
+func TestWarmStandbySkippedWhileDialFails(t *testing.T) {
+	var mu sync.Mutex
+	dials := 0
+	factory := func() (io.Writer, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		return nil, errors.New("connection refused")
+	}
+	hook := &Hook{connFactory: factory, appName: "standby_test", alwaysSentFields: logrus.Fields{}}
+	hook.WarmStandby = true
+	hook.MaxReconnectAttempts = 2
+
+	for i := 0; i < 50; i++ {
+		entry := &logrus.Entry{Message: fmt.Sprintf("entry %d", i), Data: logrus.Fields{}, Level: logrus.InfoLevel}
+		hook.Fire(entry)
+	}
+	hook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dials != hook.MaxReconnectAttempts {
+		t.Errorf("Expected %d dials, got %d", hook.MaxReconnectAttempts, dials)
+	}
+}
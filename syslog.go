@@ -0,0 +1,67 @@
+package logrus_logstash
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogWriter is implemented by *syslog.Writer.
+type syslogWriter interface {
+	Emerg(m string) error
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+	Close() error
+}
+
+// syslogLevelWriter writes entries of one level to syslog with the matching
+// priority.
+type syslogLevelWriter struct {
+	w     syslogWriter
+	level logrus.Level
+}
+
+func (s syslogLevelWriter) Write(b []byte) (int, error) {
+	m := strings.TrimSuffix(string(b), "\n")
+	var err error
+	switch s.level {
+	case logrus.PanicLevel:
+		err = s.w.Emerg(m)
+	case logrus.FatalLevel:
+		err = s.w.Crit(m)
+	case logrus.ErrorLevel:
+		err = s.w.Err(m)
+	case logrus.WarnLevel:
+		err = s.w.Warning(m)
+	case logrus.InfoLevel:
+		err = s.w.Info(m)
+	default:
+		err = s.w.Debug(m)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// syslogSeverity returns the syslog severity matching the level, as used for
+// the syslog priority of entries.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 0 // emerg
+	case logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows && !nacl && !plan9
+// +build !windows,!nacl,!plan9
+
+package logrus_logstash
+
+import (
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewSyslogHook creates a new hook which writes the Logstash JSON of every
+// entry to syslog instead of a network socket, with a priority matching the
+// entry level. network and raddr select the syslog daemon as for syslog.Dial;
+// leave them empty to use the local one. tag is the syslog tag.
+func NewSyslogHook(network, raddr, tag, appName string) (*Hook, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Hook{syslog: w, appName: appName, alwaysSentFields: make(logrus.Fields)}, nil
+}
@@ -0,0 +1,78 @@
+package logrus_logstash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type syslogMessage struct {
+	priority string
+	m        string
+}
+
+type SyslogMock struct {
+	messages []syslogMessage
+	closed   bool
+}
+
+func (s *SyslogMock) write(priority, m string) error {
+	s.messages = append(s.messages, syslogMessage{priority, m})
+	return nil
+}
+
+func (s *SyslogMock) Emerg(m string) error   { return s.write("emerg", m) }
+func (s *SyslogMock) Crit(m string) error    { return s.write("crit", m) }
+func (s *SyslogMock) Err(m string) error     { return s.write("err", m) }
+func (s *SyslogMock) Warning(m string) error { return s.write("warning", m) }
+func (s *SyslogMock) Info(m string) error    { return s.write("info", m) }
+func (s *SyslogMock) Debug(m string) error   { return s.write("debug", m) }
+func (s *SyslogMock) Close() error           { s.closed = true; return nil }
+
+func TestFireSyslog(t *testing.T) {
+	tt := []struct {
+		level    logrus.Level
+		priority string
+	}{
+		{logrus.PanicLevel, "emerg"},
+		{logrus.FatalLevel, "crit"},
+		{logrus.ErrorLevel, "err"},
+		{logrus.WarnLevel, "warning"},
+		{logrus.InfoLevel, "info"},
+		{logrus.DebugLevel, "debug"},
+	}
+
+	for _, te := range tt {
+		mock := &SyslogMock{}
+		hook := &Hook{syslog: mock, appName: "syslog_test", alwaysSentFields: logrus.Fields{}}
+		entry := &logrus.Entry{Message: "hello world!", Data: logrus.Fields{}, Level: te.level}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		if len(mock.messages) != 1 {
+			t.Fatalf("expected a single syslog message but got %d", len(mock.messages))
+		}
+		if mock.messages[0].priority != te.priority {
+			t.Errorf("expected priority to be '%s' but got '%s'", te.priority, mock.messages[0].priority)
+		}
+		var res map[string]string
+		if err := json.Unmarshal([]byte(mock.messages[0].m), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res["type"] != "syslog_test" {
+			t.Errorf("expected type to be '%s' but got '%s'", "syslog_test", res["type"])
+		}
+	}
+}
+
+func TestCloseSyslog(t *testing.T) {
+	mock := &SyslogMock{}
+	hook := &Hook{syslog: mock, appName: "syslog_test", alwaysSentFields: logrus.Fields{}}
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !mock.closed {
+		t.Error("expected the syslog writer to be closed")
+	}
+}
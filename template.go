@@ -0,0 +1,65 @@
+package logrus_logstash
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TemplateFormatter formats entries as lines rendered by a text/template,
+// for Logstash inputs with the `line` or `plain` codec. Templates are
+// executed with a TemplateEntry, e.g.
+//
+//	[{{.Level}}] {{.Message}}{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}}
+type TemplateFormatter struct {
+	template *template.Template
+}
+
+// TemplateEntry is what a TemplateFormatter's template renders.
+type TemplateEntry struct {
+	Level   string
+	Message string
+	Time    time.Time
+	// Fields maps the names of the entry's fields to their values formatted
+	// as strings. Fields the entry doesn't set render empty.
+	Fields map[string]string
+}
+
+// NewTemplateFormatter parses text as the template of a new
+// TemplateFormatter.
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	t, err := template.New("entry").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template, %v", err)
+	}
+	return &TemplateFormatter{template: t}, nil
+}
+
+// Format renders the entry as a newline-terminated line.
+func (f *TemplateFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	// Field values are formatted the way a LogstashFormatter would, e.g.
+	// errors by their message
+	var lf LogstashFormatter
+	fields := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		fields[k] = fmt.Sprint(lf.formatValue(v))
+	}
+
+	var buf bytes.Buffer
+	err := f.template.Execute(&buf, TemplateEntry{
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Time:    entry.Time,
+		Fields:  fields,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute template, %v", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
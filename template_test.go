@@ -0,0 +1,49 @@
+package logrus_logstash
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestTemplateFormatter(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "hello world!",
+		Data:    logrus.Fields{"user": "alice", "err": errors.New("boom"), "count": 3},
+		Time:    time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		Level:   logrus.WarnLevel,
+	}
+
+	tt := []struct {
+		template string
+		expected string
+	}{
+		{"[{{.Level}}] {{.Message}}", "[warning] hello world!\n"},
+		{"{{.Time.Format \"15:04\"}} {{.Message}}\n", "10:00 hello world!\n"},
+		{"{{.Message}} user={{.Fields.user}} err={{.Fields.err}}", "hello world! user=alice err=boom\n"},
+		{"{{.Message}} missing={{.Fields.missing}}.", "hello world! missing=.\n"},
+		{"{{range $k, $v := .Fields}}{{$k}}={{$v}} {{end}}", "count=3 err=boom user=alice \n"},
+	}
+
+	for _, te := range tt {
+		f, err := NewTemplateFormatter(te.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != te.expected {
+			t.Errorf("expected line to be '%q' but got '%q'", te.expected, b)
+		}
+	}
+}
+
+func TestNewTemplateFormatterInvalid(t *testing.T) {
+	if _, err := NewTemplateFormatter("{{.Message"); err == nil {
+		t.Error("expected an unterminated action to fail parsing")
+	}
+}